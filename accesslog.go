@@ -0,0 +1,190 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogFormat is the format used for access log lines
+type AccessLogFormat int
+
+const (
+	// CommonLogFormat is the NCSA Common Log Format, ie
+	// host ident authuser [date] "request line" status bytes
+	CommonLogFormat AccessLogFormat = iota
+	// CombinedLogFormat is the Common Log Format with the referer and
+	// user agent added at the end.
+	CombinedLogFormat
+	// FieldsLogFormat logs the request as key=value fields.
+	FieldsLogFormat
+)
+
+// clfTimeFormat is the time stamp format used by the Common Log Format
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// NewAccessLogHandler returns a http.Handler that logs every request handled
+// by the next handler. Server errors (5xx) are logged at error level, client
+// errors (4xx) at warning level and everything else at info level. Requests
+// for the excluded paths (typically health checks) are not logged. The
+// request ID is included if the handler is wrapped by NewRequestIDHandler.
+func NewAccessLogHandler(next http.Handler, format AccessLogFormat, excludePaths ...string) http.Handler {
+	excluded := make(map[string]bool)
+	for _, p := range excludePaths {
+		excluded[p] = true
+	}
+	return &accessLogHandler{next: next, format: format, excluded: excluded}
+}
+
+type accessLogHandler struct {
+	next     http.Handler
+	format   AccessLogFormat
+	excluded map[string]bool
+}
+
+func (a *accessLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.excluded[r.URL.Path] {
+		a.next.ServeHTTP(w, r)
+		return
+	}
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w}
+	a.next.ServeHTTP(rec, r)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	level := InfoLevel
+	switch {
+	case rec.status >= 500:
+		level = ErrorLevel
+	case rec.status >= 400:
+		level = WarningLevel
+	}
+	outputContext(r.Context(), 1, Record{Level: level, Message: a.formatRequest(r, rec, start), RequestID: RequestID(r.Context())})
+}
+
+// formatRequest formats the access log line for a request
+func (a *accessLogHandler) formatRequest(r *http.Request, rec *statusRecorder, start time.Time) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+
+	if a.format == FieldsLogFormat {
+		return fmt.Sprintf("method=%s path=%s status=%d bytes=%d duration=%s remote=%s user_agent=%s",
+			fieldValue(r.Method),
+			fieldValue(r.URL.Path),
+			rec.status,
+			rec.bytes,
+			time.Since(start),
+			fieldValue(host),
+			fieldValue(r.UserAgent()))
+	}
+
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %d",
+		host,
+		user,
+		start.Format(clfTimeFormat),
+		r.Method,
+		r.RequestURI,
+		r.Proto,
+		rec.status,
+		rec.bytes)
+	if a.format == CombinedLogFormat {
+		line += fmt.Sprintf(" %s %s", strconv.Quote(r.Referer()), strconv.Quote(r.UserAgent()))
+	}
+	return line
+}
+
+// fieldValue quotes the value if it is empty or contains spaces, quotes or
+// equal signs.
+func fieldValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// statusRecorder is a http.ResponseWriter that records the status code and
+// the number of bytes written.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer supports it
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer supports it. The
+// status is logged as 101 (Switching Protocols) since the handler takes over
+// the connection, ie for WebSocket upgrades.
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		s.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Push implements http.Pusher if the underlying writer supports it
+func (s *statusRecorder) Push(target string, opts *http.PushOptions) error {
+	if p, ok := s.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying writer. This is used by
+// http.ResponseController.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
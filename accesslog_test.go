@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(DebugLevel)
+	defer SetLogLevel(WarningLevel)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "failed", http.StatusInternalServerError)
+	})
+
+	doRequest := func(h http.Handler, path string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("User-Agent", "test agent")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	h := NewAccessLogHandler(mux, CombinedLogFormat, "/health")
+	doRequest(h, "/ok")
+	doRequest(h, "/health")
+	doRequest(h, "/missing")
+	doRequest(h, "/fail")
+
+	info := logs[InfoLevel].Entries()
+	if len(info) != 1 {
+		t.Fatalf("Expected 1 info entry but got %d", len(info))
+	}
+	if !strings.Contains(info[0].Message, `"GET /ok HTTP/1.1" 200 5 "" "test agent"`) {
+		t.Fatalf("Incorrect combined log line: %s", info[0].Message)
+	}
+	if len(logs[WarningLevel].Entries()) != 1 {
+		t.Fatal("Expected 404 to be logged as warning")
+	}
+	if len(logs[ErrorLevel].Entries()) != 1 {
+		t.Fatal("Expected 500 to be logged as error")
+	}
+
+	doRequest(NewAccessLogHandler(mux, FieldsLogFormat), "/ok")
+	info = logs[InfoLevel].Entries()
+	msg := info[len(info)-1].Message
+	if !strings.Contains(msg, `method=GET path=/ok status=200 bytes=5 duration=`) ||
+		!strings.Contains(msg, `remote=192.0.2.1 user_agent="test agent"`) {
+		t.Fatalf("Incorrect fields log line: %s", msg)
+	}
+}
+
+func TestAccessLogHijack(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(DebugLevel)
+	defer SetLogLevel(WarningLevel)
+
+	server := httptest.NewServer(NewAccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := w.(http.Pusher).Push("/style.css", nil); err != http.ErrNotSupported {
+			t.Errorf("Expected push to be unsupported but got %v", err)
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+	}), CommonLogFormat))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || !strings.Contains(status, "101") {
+		t.Fatalf("Expected upgrade but got %q: %v", status, err)
+	}
+
+	start := time.Now()
+	for len(logs[InfoLevel].Entries()) == 0 && time.Since(start) < 2*time.Second {
+		time.Sleep(5 * time.Millisecond)
+	}
+	info := logs[InfoLevel].Entries()
+	if len(info) != 1 || !strings.Contains(info[0].Message, `"GET /ws HTTP/1.1" 101 `) {
+		t.Fatalf("Expected hijacked request to be logged with status 101: %+v", info)
+	}
+}
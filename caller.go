@@ -0,0 +1,73 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// callerSkip is the number of extra frames to skip when determining the caller
+var callerSkip int32
+
+// helpers is the set of functions (by name) that have called Helper
+var helpers sync.Map
+
+// hasHelpers is set to 1 when the first helper is registered so the stack
+// isn't inspected unless it is required.
+var hasHelpers uint32
+
+// SetCallerSkip sets the number of additional stack frames to skip when the
+// location of the log message is determined. Use this if all log calls go
+// through the same number of wrapper functions.
+func SetCallerSkip(skip int) {
+	atomic.StoreInt32(&callerSkip, int32(skip))
+}
+
+// Helper marks the calling function as a logging helper function, just like
+// testing.T.Helper. The helper's frame is skipped when the location of the
+// log message is determined.
+func Helper() {
+	pcs := make([]uintptr, 1)
+	if runtime.Callers(2, pcs) == 0 {
+		return
+	}
+	frame, _ := runtime.CallersFrames(pcs).Next()
+	if _, loaded := helpers.LoadOrStore(frame.Function, true); !loaded {
+		atomic.StoreUint32(&hasHelpers, 1)
+	}
+}
+
+// callerDepth adjusts the call depth passed to emit with the caller skip
+// and any helper functions.
+func callerDepth(calldepth int) int {
+	calldepth += int(atomic.LoadInt32(&callerSkip))
+	if atomic.LoadUint32(&hasHelpers) == 0 {
+		return calldepth
+	}
+	// Skip runtime.Callers, callerDepth and emit
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(calldepth+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if _, helper := helpers.Load(frame.Function); !helper || !more {
+			return calldepth
+		}
+		calldepth++
+	}
+}
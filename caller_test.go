@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func wrappedWarning(msg string) {
+	Helper()
+	Warning("%s", msg)
+}
+
+func doubleWrappedWarning(msg string) {
+	Helper()
+	wrappedWarning(msg)
+}
+
+func unmarkedWarning(msg string) {
+	Warning("%s", msg)
+}
+
+// line returns the file:line of the caller
+func line() string {
+	_, file, line, _ := runtime.Caller(1)
+	return fmt.Sprintf("%s:%d", file[strings.LastIndex(file, "/")+1:], line)
+}
+
+func TestHelperAndCallerSkip(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	setMemoryOutput(t, logs)
+	setLogLevel(t, WarningLevel)
+	t.Cleanup(func() { SetCallerSkip(0) })
+
+	expected := []string{}
+	wrappedWarning("helper")
+	expected = append(expected, line())
+	doubleWrappedWarning("nested helpers")
+	expected = append(expected, line())
+
+	SetCallerSkip(1)
+	unmarkedWarning("skip")
+	expected = append(expected, line())
+	SetCallerSkip(0)
+
+	entries := logs[WarningLevel].Entries()
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries but got %d", len(expected), len(entries))
+	}
+	for i, e := range entries {
+		// The line after the call is recorded
+		var file string
+		var n int
+		fmt.Sscanf(strings.Replace(expected[i], ":", " ", 1), "%s %d", &file, &n)
+		if e.Location != fmt.Sprintf("%s:%d", file, n-1) {
+			t.Fatalf("Entry %d (%s) has location %s, expected %s:%d", i, e.Message, e.Location, file, n-1)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+
+// componentMarker is the detail line with the component name. It follows
+// the log message in the text outputs for messages logged through a
+// Component.
+const componentMarker = "\tcomponent "
+
+// Component is a named part of the application, like "store" or "api". The
+// log level can be set for each component with SetComponentLevels and the
+// component name is included in the log entries.
+type Component struct {
+	name string
+}
+
+// NewComponent creates a new component with the name
+func NewComponent(name string) *Component {
+	return &Component{name: name}
+}
+
+// Name returns the component's name
+func (c *Component) Name() string {
+	return c.name
+}
+
+// SetComponentLevels sets the log levels for the named components. Components
+// that aren't in the map use the global log level set by SetLogLevel.
+func SetComponentLevels(levels map[string]uint) {
+	m := make(map[string]uint, len(levels))
+	for k, v := range levels {
+		m[k] = v
+	}
+	updateSettings(func(s *settings) {
+		s.components = m
+	})
+}
+
+// Debug adds a debug-level log message for the component
+func (c *Component) Debug(format string, v ...interface{}) {
+	outputf(DebugLevel, 2, c.name, format, v)
+}
+
+// Info adds an info-level log message for the component
+func (c *Component) Info(format string, v ...interface{}) {
+	outputf(InfoLevel, 2, c.name, format, v)
+}
+
+// Warning adds a warning-level log message for the component
+func (c *Component) Warning(format string, v ...interface{}) {
+	outputf(WarningLevel, 2, c.name, format, v)
+}
+
+// Error adds an error-level log message for the component
+func (c *Component) Error(format string, v ...interface{}) {
+	outputf(ErrorLevel, 2, c.name, format, v)
+}
+
+// Err adds an error-level log message for an error value for the component.
+// See the Err function for details.
+func (c *Component) Err(err error) {
+	if err == nil {
+		return
+	}
+	c.output(2, errorRecord(err, 2))
+}
+
+// output writes the record if the component's log level permits it. The
+// flight recorder keeps the messages below the level, as for the global log
+// functions.
+func (c *Component) output(calldepth int, r Record) {
+	r.Component = c.name
+	outputTo(nil, calldepth+1, r)
+}
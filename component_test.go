@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestComponentLevels(t *testing.T) {
+	setLogLevel(t, WarningLevel)
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetComponentLevels(map[string]uint{"quiet": ErrorLevel, "chatty": DebugLevel})
+	defer SetComponentLevels(nil)
+
+	quiet := NewComponent("quiet")
+	chatty := NewComponent("chatty")
+	other := NewComponent("other")
+	quiet.Warning("dropped")
+	quiet.Err(errors.New("kept"))
+	chatty.Debug("kept")
+	other.Debug("dropped")
+	other.Warning("kept")
+
+	for level, expected := range []int{1, 0, 1, 1} {
+		entries := logs[level].Entries()
+		if len(entries) != expected {
+			t.Fatalf("Expected %d entries at level %d but got %d", expected, level, len(entries))
+		}
+		for _, e := range entries {
+			if !strings.Contains(e.Message, "kept") || e.Component == "" {
+				t.Fatalf("Incorrect entry: %+v", e)
+			}
+			if !strings.HasPrefix(e.Location, "component_test.go:") {
+				t.Fatalf("Incorrect location: %s", e.Location)
+			}
+		}
+	}
+	if e := logs[ErrorLevel].Entries()[0]; e.Component != "quiet" || len(e.Causes) != 1 {
+		t.Fatalf("Expected error details and component: %+v", e)
+	}
+}
@@ -0,0 +1,321 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output types for the configuration
+const (
+	StderrOutput = "stderr"
+	SyslogOutput = "syslog"
+	MemoryOutput = "memory"
+)
+
+// Formats for the stderr output
+const (
+	PlainFormat = "plain"
+	ColorFormat = "color"
+)
+
+// Config is the logging configuration. It can be loaded from a file with
+// LoadConfig and from environment variables with Config.LoadEnv. Empty
+// fields use the defaults. One output is configured; messages can be copied
+// to a MappedLogger as well with SetMirror. JSON files are decoded by
+// default. The package has no dependencies so the YAML and TOML decoders
+// must be registered with RegisterConfigFormat; the yaml and toml struct
+// tags are set for them.
+type Config struct {
+	// Level is the log level name (debug, info, warning or error). The
+	// default is warning.
+	Level string `json:"level,omitempty" yaml:"level,omitempty" toml:"level,omitempty"`
+	// Output is stderr, syslog or memory. The default is stderr.
+	Output string `json:"output,omitempty" yaml:"output,omitempty" toml:"output,omitempty"`
+	// Format is plain or color for the stderr output. The default is plain.
+	Format string `json:"format,omitempty" yaml:"format,omitempty" toml:"format,omitempty"`
+	// Theme is dark, light or monochrome for the color format. The default
+	// is dark.
+	Theme string `json:"theme,omitempty" yaml:"theme,omitempty" toml:"theme,omitempty"`
+	// SyslogName is the name used for the syslog output. The default is the
+	// name of the executable.
+	SyslogName string `json:"syslogName,omitempty" yaml:"syslogName,omitempty" toml:"syslogName,omitempty"`
+	// MemoryEntries is the number of entries for each level for the memory
+	// output. The default is 1000.
+	MemoryEntries int `json:"memoryEntries,omitempty" yaml:"memoryEntries,omitempty" toml:"memoryEntries,omitempty"`
+	// Components maps component names to log level names. These override
+	// the log level for the components.
+	Components map[string]string `json:"components,omitempty" yaml:"components,omitempty" toml:"components,omitempty"`
+	// Sampling limits the number of messages logged
+	Sampling SamplingConfig `json:"sampling,omitempty" yaml:"sampling,omitempty" toml:"sampling,omitempty"`
+}
+
+// SamplingConfig is the sampling configuration. See SetSampling for details.
+type SamplingConfig struct {
+	Initial    int    `json:"initial,omitempty" yaml:"initial,omitempty" toml:"initial,omitempty"`
+	Thereafter int    `json:"thereafter,omitempty" yaml:"thereafter,omitempty" toml:"thereafter,omitempty"`
+	Interval   string `json:"interval,omitempty" yaml:"interval,omitempty" toml:"interval,omitempty"`
+}
+
+// defaultMemoryEntries is the default number of entries for the memory output
+const defaultMemoryEntries = 1000
+
+// UnmarshalFunc is a function that decodes a configuration file, like
+// json.Unmarshal or yaml.Unmarshal.
+type UnmarshalFunc func(data []byte, v interface{}) error
+
+var (
+	formatMutex   sync.Mutex
+	configFormats = map[string]UnmarshalFunc{".json": json.Unmarshal}
+)
+
+// RegisterConfigFormat registers a decoder for configuration files with the
+// extension. JSON is supported by default. YAML and TOML aren't built in;
+// register their decoders with f.e. RegisterConfigFormat(".yaml",
+// yaml.Unmarshal) and RegisterConfigFormat(".toml", toml.Unmarshal).
+func RegisterConfigFormat(ext string, unmarshal UnmarshalFunc) {
+	formatMutex.Lock()
+	defer formatMutex.Unlock()
+	configFormats[strings.ToLower(ext)] = unmarshal
+}
+
+// LoadConfig reads the configuration from a file. The format is determined
+// by the file extension.
+func LoadConfig(filename string) (Config, error) {
+	var ret Config
+	ext := strings.ToLower(filepath.Ext(filename))
+	formatMutex.Lock()
+	unmarshal, ok := configFormats[ext]
+	formatMutex.Unlock()
+	if !ok {
+		return ret, fmt.Errorf("no decoder registered for %s files", ext)
+	}
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return ret, err
+	}
+	if err := unmarshal(buf, &ret); err != nil {
+		return ret, fmt.Errorf("unable to decode %s: %v", filename, err)
+	}
+	return ret, nil
+}
+
+// LoadEnv overrides the configuration with environment variables. The names
+// of the variables are the prefix followed by LEVEL, OUTPUT, FORMAT, THEME,
+// SYSLOG_NAME, MEMORY_ENTRIES, COMPONENTS, SAMPLING_INITIAL,
+// SAMPLING_THEREAFTER and SAMPLING_INTERVAL, f.e. LOG_LEVEL with the prefix
+// "LOG_". Components are set as a comma-separated list of name=level pairs.
+func (c *Config) LoadEnv(prefix string) error {
+	str := func(name string, field *string) {
+		if v, ok := os.LookupEnv(prefix + name); ok {
+			*field = v
+		}
+	}
+	num := func(name string, field *int) error {
+		v, ok := os.LookupEnv(prefix + name)
+		if !ok {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s%s must be a number: %v", prefix, name, err)
+		}
+		*field = n
+		return nil
+	}
+	str("LEVEL", &c.Level)
+	str("OUTPUT", &c.Output)
+	str("FORMAT", &c.Format)
+	str("THEME", &c.Theme)
+	str("SYSLOG_NAME", &c.SyslogName)
+	str("SAMPLING_INTERVAL", &c.Sampling.Interval)
+	if err := num("MEMORY_ENTRIES", &c.MemoryEntries); err != nil {
+		return err
+	}
+	if err := num("SAMPLING_INITIAL", &c.Sampling.Initial); err != nil {
+		return err
+	}
+	if err := num("SAMPLING_THEREAFTER", &c.Sampling.Thereafter); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv(prefix + "COMPONENTS"); ok {
+		c.Components = make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("%sCOMPONENTS must be name=level pairs but got %q", prefix, pair)
+			}
+			c.Components[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return nil
+}
+
+// appliedConfig is the validated configuration, ready to be applied
+type appliedConfig struct {
+	level           uint
+	output          string
+	plainText       bool
+	theme           Theme
+	syslogName      string
+	memoryEntries   int
+	componentLevels map[string]uint
+	initial         int
+	thereafter      int
+	interval        time.Duration
+}
+
+// validate checks the configuration and converts it into an appliedConfig
+func (c Config) validate() (*appliedConfig, error) {
+	ret := &appliedConfig{
+		level:           WarningLevel,
+		output:          StderrOutput,
+		plainText:       true,
+		theme:           DarkTheme,
+		syslogName:      filepath.Base(os.Args[0]),
+		memoryEntries:   defaultMemoryEntries,
+		componentLevels: make(map[string]uint),
+	}
+	var err error
+	if c.Level != "" {
+		if ret.level, err = ParseLevel(c.Level); err != nil {
+			return nil, err
+		}
+	}
+	switch strings.ToLower(c.Output) {
+	case "", StderrOutput:
+	case SyslogOutput, MemoryOutput:
+		ret.output = strings.ToLower(c.Output)
+	default:
+		return nil, fmt.Errorf("unknown output %q", c.Output)
+	}
+	switch strings.ToLower(c.Format) {
+	case "", PlainFormat:
+	case ColorFormat:
+		ret.plainText = false
+	default:
+		return nil, fmt.Errorf("unknown format %q", c.Format)
+	}
+	switch strings.ToLower(c.Theme) {
+	case "", "dark":
+	case "light":
+		ret.theme = LightTheme
+	case "monochrome":
+		ret.theme = MonochromeTheme
+	default:
+		return nil, fmt.Errorf("unknown theme %q", c.Theme)
+	}
+	if c.SyslogName != "" {
+		ret.syslogName = c.SyslogName
+	}
+	if c.MemoryEntries < 0 {
+		return nil, errors.New("memory entries can't be negative")
+	}
+	if c.MemoryEntries > 0 {
+		ret.memoryEntries = c.MemoryEntries
+	}
+	for name, level := range c.Components {
+		if ret.componentLevels[name], err = ParseLevel(level); err != nil {
+			return nil, fmt.Errorf("component %s: %v", name, err)
+		}
+	}
+	if c.Sampling.Initial < 0 || c.Sampling.Thereafter < 0 {
+		return nil, errors.New("sampling counts can't be negative")
+	}
+	ret.initial, ret.thereafter = c.Sampling.Initial, c.Sampling.Thereafter
+	if c.Sampling.Interval != "" {
+		if ret.interval, err = time.ParseDuration(c.Sampling.Interval); err != nil {
+			return nil, fmt.Errorf("invalid sampling interval: %v", err)
+		}
+	}
+	return ret, nil
+}
+
+var (
+	configMutex sync.Mutex
+	memoryLogs  []*MemoryLogger
+)
+
+// Configure validates the configuration and applies it. The outputs, log
+// level, component levels, sampling and theme are swapped in at once so no
+// message is logged with a mix of the old and the new configuration. If the
+// configuration is invalid or the output can't be set up an error is
+// returned and the current configuration is kept. The memory logs are kept
+// if the memory output was configured before with the same number of
+// entries.
+func Configure(config Config) error {
+	c, err := config.validate()
+	if err != nil {
+		return err
+	}
+
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	var o *outputs
+	var logs []*MemoryLogger
+	var theme *Theme
+	switch c.output {
+	case SyslogOutput:
+		writers, err := newSyslogWriters(c.syslogName)
+		if err != nil {
+			return err
+		}
+		o = syslogOutputs(writers)
+	case MemoryOutput:
+		logs = memoryLogs
+		if logs == nil || logs[0].maxEntries != c.memoryEntries {
+			logs = NewMemoryLoggers(c.memoryEntries)
+		}
+		if o, err = memoryOutputs(logs); err != nil {
+			return err
+		}
+	default:
+		theme = &c.theme
+		o = stderrOutputs(c.plainText, c.theme)
+	}
+	samplers := newSamplers(c.initial, c.thereafter, c.interval)
+	updateSettings(func(s *settings) {
+		s.outputs = o
+		s.level = c.level
+		s.components = c.componentLevels
+		s.samplers = samplers
+		if theme != nil {
+			s.theme = *theme
+		}
+	})
+	memoryLogs = logs
+	return nil
+}
+
+// MemoryLogs returns the memory logs created by Configure when the output is
+// set to memory. Nil is returned for the other outputs.
+func MemoryLogs() []*MemoryLogger {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	return memoryLogs
+}
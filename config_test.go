@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "logging.json")
+	if err := ioutil.WriteFile(filename, []byte(`{
+		"level": "info",
+		"output": "memory",
+		"memoryEntries": 20,
+		"components": {"store": "debug"},
+		"sampling": {"initial": 10, "thereafter": 5, "interval": "1s"}
+	}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Level != "info" || config.MemoryEntries != 20 || config.Components["store"] != "debug" || config.Sampling.Interval != "1s" {
+		t.Fatalf("Incorrect config: %+v", config)
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "logging.yaml")); err == nil {
+		t.Fatal("Expected error for unregistered format")
+	}
+
+	os.Setenv("TESTLOG_LEVEL", "error")
+	os.Setenv("TESTLOG_COMPONENTS", "api=info, store=warning")
+	defer os.Unsetenv("TESTLOG_LEVEL")
+	defer os.Unsetenv("TESTLOG_COMPONENTS")
+	if err := config.LoadEnv("TESTLOG_"); err != nil {
+		t.Fatal(err)
+	}
+	if config.Level != "error" || config.Components["api"] != "info" || config.Components["store"] != "warning" {
+		t.Fatalf("Environment should override the config: %+v", config)
+	}
+	os.Setenv("TESTLOG_MEMORY_ENTRIES", "many")
+	defer os.Unsetenv("TESTLOG_MEMORY_ENTRIES")
+	if err := config.LoadEnv("TESTLOG_"); err == nil {
+		t.Fatal("Expected error for invalid number")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	defer Configure(Config{})
+
+	invalid := []Config{
+		{Level: "verbose"},
+		{Output: "file"},
+		{Format: "fancy"},
+		{Theme: "neon"},
+		{Components: map[string]string{"store": "loud"}},
+		{Sampling: SamplingConfig{Interval: "soon"}},
+		{MemoryEntries: -1},
+	}
+	for _, c := range invalid {
+		if err := Configure(c); err == nil {
+			t.Fatalf("Expected error for %+v", c)
+		}
+	}
+
+	if err := Configure(Config{Level: "info", Output: MemoryOutput, Components: map[string]string{"store": "debug"}}); err != nil {
+		t.Fatal(err)
+	}
+	logs := MemoryLogs()
+	if len(logs) != 4 {
+		t.Fatal("Expected memory logs")
+	}
+	if err := Configure(Config{Output: MemoryOutput, Level: "nope"}); err == nil {
+		t.Fatal("Expected error")
+	}
+	if len(MemoryLogs()) != 4 || MemoryLogs()[0] != logs[0] {
+		t.Fatal("Invalid configuration should not change the outputs")
+	}
+
+	Debug("not logged")
+	Info("logged")
+	NewComponent("store").Debug("component debug")
+	NewComponent("api").Debug("not logged")
+	if len(logs[InfoLevel].Entries()) != 1 {
+		t.Fatal("Expected info message")
+	}
+	debug := logs[DebugLevel].Entries()
+	if len(debug) != 1 || debug[0].Component != "store" || !strings.Contains(debug[0].Message, "component debug") {
+		t.Fatalf("Expected debug message from component: %+v", debug)
+	}
+
+	theme := getTheme()
+	if err := Configure(Config{Theme: "light", Output: MemoryOutput}); err != nil || getTheme() != theme {
+		t.Fatalf("The theme should only be set for stderr (err: %v)", err)
+	}
+	if err := Configure(Config{Theme: "light"}); err != nil || getTheme() != LightTheme {
+		t.Fatalf("Expected the light theme (err: %v)", err)
+	}
+}
+
+// Log while switching between two configurations. The messages must never be
+// logged with the outputs of one and the level of the other.
+func TestConfigureAtomically(t *testing.T) {
+	defer Configure(Config{})
+	verbose := Config{Level: "debug", Output: MemoryOutput, MemoryEntries: 10}
+	quiet := Config{Level: "error", Output: MemoryOutput, MemoryEntries: 20}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				Debug("debug message")
+			}
+		}
+	}()
+	var quietLogs [][]*MemoryLogger
+	for i := 0; i < 200; i++ {
+		config := verbose
+		if i%2 == 1 {
+			config = quiet
+		}
+		if err := Configure(config); err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 1 {
+			quietLogs = append(quietLogs, MemoryLogs())
+		}
+	}
+	close(done)
+	wg.Wait()
+	for _, logs := range quietLogs {
+		if n := logs[DebugLevel].NumEntries(); n != 0 {
+			t.Fatalf("Expected no debug messages with the quiet configuration but got %d", n)
+		}
+	}
+}
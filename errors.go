@@ -0,0 +1,221 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrorCause is a single error in an error chain.
+type ErrorCause struct {
+	Type    string
+	Message string
+}
+
+// StackFrame is a single frame in a stack trace.
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// String returns the frame formatted as "function (file:line)"
+func (s StackFrame) String() string {
+	return fmt.Sprintf("%s (%s:%d)", s.Function, s.File, s.Line)
+}
+
+// Markers used for the detail lines following the message when an error value
+// is logged to the text outputs. NewLogEntry uses these to reconstruct the
+// causes and the stack trace from text.
+const (
+	causeMarker = "\tcause "
+	frameMarker = "\tat "
+)
+
+// maxStackDepth is the maximum number of frames captured at the call site
+const maxStackDepth = 32
+
+// Err adds an error-level log message for an error value. The chain of
+// wrapped errors (via errors.Unwrap and errors.Join) is recorded along with a
+// stack trace. If one of the errors in the chain carries a stack trace (ie
+// has a StackTrace() method returning program counters like the
+// github.com/pkg/errors package does) that trace is used, otherwise the stack
+// trace is captured at the call site.
+func Err(err error) {
+	if err == nil {
+		return
+	}
+	outputTo(nil, 2, errorRecord(err, 2))
+}
+
+// errorRecord returns the record for an error value. The calldepth parameter
+// is relative to the caller of errorRecord, the same way as for emit, so the
+// stack trace captured at the call site starts at the location of the entry.
+func errorRecord(err error, calldepth int) Record {
+	// Skip runtime.Callers, errorStack and errorRecord
+	stack := errorStack(err, callerDepth(calldepth)+2)
+	return Record{Level: ErrorLevel, Message: err.Error(), Causes: errorChain(err), Stack: stack}
+}
+
+// errorChain walks the error chain and returns the causes, starting with the
+// error itself. Joined errors are visited depth first.
+func errorChain(err error) []ErrorCause {
+	var ret []ErrorCause
+	var walk func(e error)
+	walk = func(e error) {
+		for e != nil {
+			ret = append(ret, ErrorCause{Type: fmt.Sprintf("%T", e), Message: e.Error()})
+			if joined, ok := e.(interface{ Unwrap() []error }); ok {
+				for _, j := range joined.Unwrap() {
+					walk(j)
+				}
+				return
+			}
+			e = errors.Unwrap(e)
+		}
+	}
+	walk(err)
+	return ret
+}
+
+// errorStack returns the stack trace carried by the error chain or the stack
+// trace of the caller if there's none. The skip parameter is the number of
+// frames to skip, as for runtime.Callers.
+func errorStack(err error, skip int) []StackFrame {
+	if pcs := carriedStack(err); len(pcs) > 0 {
+		return framesFromPCs(pcs)
+	}
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	return framesFromPCs(pcs[:n])
+}
+
+// carriedStack returns the innermost stack trace found in the error chain.
+// Joined errors are visited depth first, the same way as in errorChain. Any
+// StackTrace() method returning a slice of uintptr-based values is accepted
+// so we won't have to import the packages that provide them.
+func carriedStack(err error) []uintptr {
+	var ret []uintptr
+	var walk func(e error)
+	walk = func(e error) {
+		for ; e != nil; e = errors.Unwrap(e) {
+			if pcs := errorPCs(e); len(pcs) > 0 {
+				ret = pcs
+			}
+			if joined, ok := e.(interface{ Unwrap() []error }); ok {
+				for _, j := range joined.Unwrap() {
+					walk(j)
+				}
+				return
+			}
+		}
+	}
+	walk(err)
+	return ret
+}
+
+// errorPCs returns the program counters from the error's StackTrace() method
+// or nil if there's no such method.
+func errorPCs(e error) []uintptr {
+	m := reflect.ValueOf(e).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	out := m.Type().Out(0)
+	if out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	trace := m.Call(nil)[0]
+	pcs := make([]uintptr, trace.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(trace.Index(i).Uint())
+	}
+	return pcs
+}
+
+func framesFromPCs(pcs []uintptr) []StackFrame {
+	var ret []StackFrame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if f.Function != "" || f.File != "" {
+			ret = append(ret, StackFrame{Function: f.Function, File: f.File, Line: f.Line})
+		}
+		if !more {
+			return ret
+		}
+	}
+}
+
+// formatErrorDetails formats the causes and stack frames as lines that are
+// appended to the log message for the text outputs. The first cause is the
+// error itself so only the type is written for it; the message is the log
+// message.
+func formatErrorDetails(causes []ErrorCause, stack []StackFrame) string {
+	var sb strings.Builder
+	for i, c := range causes {
+		if i == 0 {
+			sb.WriteString("\n" + causeMarker + c.Type)
+			continue
+		}
+		sb.WriteString("\n" + causeMarker + c.Type + ": " + strings.Replace(c.Message, "\n", "\\n", -1))
+	}
+	for _, f := range stack {
+		sb.WriteString("\n" + frameMarker + f.String())
+	}
+	return sb.String()
+}
+
+// parseErrorDetails is the inverse of formatErrorDetails. The message is the
+// log message preceding the detail lines.
+func parseErrorDetails(message string, lines []string) ([]ErrorCause, []StackFrame) {
+	var causes []ErrorCause
+	var stack []StackFrame
+	for _, l := range lines {
+		switch {
+		case strings.HasPrefix(l, causeMarker):
+			c := strings.TrimPrefix(l, causeMarker)
+			if len(causes) == 0 {
+				causes = append(causes, ErrorCause{Type: c, Message: strings.TrimSpace(message)})
+				continue
+			}
+			fields := strings.SplitN(c, ": ", 2)
+			cause := ErrorCause{Type: fields[0]}
+			if len(fields) > 1 {
+				cause.Message = strings.Replace(fields[1], "\\n", "\n", -1)
+			}
+			causes = append(causes, cause)
+		case strings.HasPrefix(l, frameMarker):
+			f := strings.TrimPrefix(l, frameMarker)
+			frame := StackFrame{Function: f}
+			if open := strings.LastIndex(f, " ("); open >= 0 && strings.HasSuffix(f, ")") {
+				frame.Function = f[:open]
+				loc := f[open+2 : len(f)-1]
+				if colon := strings.LastIndex(loc, ":"); colon >= 0 {
+					frame.File = loc[:colon]
+					frame.Line, _ = strconv.Atoi(loc[colon+1:])
+				}
+			}
+			stack = append(stack, frame)
+		}
+	}
+	return causes, stack
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type frame uintptr
+
+type stackError struct {
+	pcs []uintptr
+}
+
+func (s *stackError) Error() string {
+	return "stack error"
+}
+
+func (s *stackError) StackTrace() []frame {
+	ret := make([]frame, len(s.pcs))
+	for i := range s.pcs {
+		ret[i] = frame(s.pcs[i])
+	}
+	return ret
+}
+
+func newStackError() error {
+	pcs := make([]uintptr, 10)
+	n := runtime.Callers(1, pcs)
+	return &stackError{pcs: pcs[:n]}
+}
+
+func TestErrorChain(t *testing.T) {
+	_, osErr := os.Open("/this/does/not/exist")
+	err := fmt.Errorf("outer: %w", errors.Join(errors.New("first"), fmt.Errorf("second: %w", osErr)))
+
+	causes := errorChain(err)
+	if len(causes) != 6 {
+		t.Fatalf("Expected 6 causes but got %d: %v", len(causes), causes)
+	}
+	if causes[0].Type != "*fmt.wrapError" || causes[5].Type != "syscall.Errno" {
+		t.Fatalf("Unexpected cause types: %v", causes)
+	}
+	if causes[0].Message != err.Error() {
+		t.Fatalf("Expected first cause to be the error itself: %v", causes[0])
+	}
+}
+
+func TestErrLogging(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+
+	Err(nil)
+	Err(fmt.Errorf("wrapped:\n %w", errors.New("inner")))
+	Err(fmt.Errorf("with stack: %w", newStackError()))
+	Err(errors.Join(errors.New("first"), fmt.Errorf("joined: %w", newStackError())))
+	logErrHelper(errors.New("from helper"))
+
+	entries := logs[ErrorLevel].Entries()
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 entries but got %d", len(entries))
+	}
+	e := entries[0]
+	if !strings.HasPrefix(e.Location, "errors_test.go:") {
+		t.Fatalf("Incorrect location: %s", e.Location)
+	}
+	if strings.TrimSpace(e.Message) != "wrapped:\n inner" {
+		t.Fatalf("Incorrect message: %q", e.Message)
+	}
+	if len(e.Causes) != 2 || e.Causes[1].Type != "*errors.errorString" || e.Causes[1].Message != "inner" {
+		t.Fatalf("Incorrect causes: %v", e.Causes)
+	}
+	if len(e.Stack) == 0 || !strings.HasSuffix(e.Stack[0].Function, "TestErrLogging") || e.Stack[0].Line == 0 {
+		t.Fatalf("Stack should start at the call site: %v", e.Stack)
+	}
+
+	e = entries[1]
+	if len(e.Stack) == 0 || !strings.HasSuffix(e.Stack[0].Function, "newStackError") {
+		t.Fatalf("Stack should be the one carried by the error: %v", e.Stack)
+	}
+	e = entries[2]
+	if len(e.Stack) == 0 || !strings.HasSuffix(e.Stack[0].Function, "newStackError") {
+		t.Fatalf("Stack should be the one carried by the joined error: %v", e.Stack)
+	}
+	e = entries[3]
+	if len(e.Stack) == 0 || !strings.HasSuffix(e.Stack[0].Function, "TestErrLogging") || !strings.HasSuffix(e.Stack[0].File, e.Location[:strings.IndexByte(e.Location, ':')]) {
+		t.Fatalf("Stack should start at the location, skipping the helper: %s %v", e.Location, e.Stack)
+	}
+}
+
+func logErrHelper(err error) {
+	Helper()
+	Err(err)
+}
@@ -0,0 +1,147 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// Logger is a logger instance with its own memory logs and log level. Use
+// this when the global logs can't be used, f.e. in tests that run in
+// parallel. The context-aware log functions (InfoContext and so on) will
+// use the Logger in the context if there is one.
+type Logger struct {
+	loggers []*log.Logger
+	logs    []*MemoryLogger
+	level   uint32
+}
+
+// NewLogger creates a new Logger instance that writes to the memory loggers,
+// one for each level as returned by NewMemoryLoggers. The log level is set to
+// DebugLevel.
+func NewLogger(logs []*MemoryLogger) (*Logger, error) {
+	if len(logs) <= int(ErrorLevel) {
+		return nil, fmt.Errorf("expected %d logs, got %d", ErrorLevel+1, len(logs))
+	}
+	ret := &Logger{logs: logs, level: uint32(DebugLevel)}
+	for _, m := range logs[:ErrorLevel+1] {
+		ret.loggers = append(ret.loggers, log.New(m, "", MemoryLoggerFlags))
+	}
+	return ret, nil
+}
+
+// SetLogLevel sets the logging level for the logger
+func (l *Logger) SetLogLevel(level uint) {
+	atomic.StoreUint32(&l.level, uint32(level))
+}
+
+// Logs returns the memory logs for the logger
+func (l *Logger) Logs() []*MemoryLogger {
+	return l.logs
+}
+
+// Debug adds a debug-level log message to the logger
+func (l *Logger) Debug(format string, v ...interface{}) {
+	l.outputf(2, DebugLevel, format, v)
+}
+
+// Info adds an info-level log message to the logger
+func (l *Logger) Info(format string, v ...interface{}) {
+	l.outputf(2, InfoLevel, format, v)
+}
+
+// Warning adds a warning-level log message to the logger
+func (l *Logger) Warning(format string, v ...interface{}) {
+	l.outputf(2, WarningLevel, format, v)
+}
+
+// Error adds an error-level log message to the logger
+func (l *Logger) Error(format string, v ...interface{}) {
+	l.outputf(2, ErrorLevel, format, v)
+}
+
+// Err adds an error-level log message for an error value to the logger. See
+// the Err function for details.
+func (l *Logger) Err(err error) {
+	if err == nil {
+		return
+	}
+	l.output(2, errorRecord(err, 2))
+}
+
+// outputf formats the message and writes it to the logger. The message is
+// only formatted if the logger's level permits it.
+func (l *Logger) outputf(calldepth int, level uint, format string, v []interface{}) {
+	if !levelEnabled(level, atomic.LoadUint32(&l.level)) {
+		return
+	}
+	l.output(calldepth+1, Record{Level: level, Message: fmt.Sprintf(format, v...)})
+}
+
+// output is the same as the write function but for the logger instance. The
+// record is dropped if the logger's level doesn't permit it.
+func (l *Logger) output(calldepth int, r Record) {
+	if !levelEnabled(r.Level, atomic.LoadUint32(&l.level)) {
+		return
+	}
+	if r.Level > ErrorLevel {
+		r.Level = ErrorLevel
+	}
+	emit(l.loggers[r.Level], nil, calldepth+1, r)
+}
+
+// WithLogger returns a copy of the context with the Logger set. The
+// context-aware log functions will write to the Logger instead of the global
+// outputs.
+func WithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// LoggerFromContext returns the Logger in the context or nil if there's none.
+func LoggerFromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return nil
+	}
+	l, _ := ctx.Value(loggerKey).(*Logger)
+	return l
+}
+
+// outputContext writes the record to the Logger in the context or the
+// global outputs if there's no Logger in the context.
+func outputContext(ctx context.Context, calldepth int, r Record) {
+	if l := LoggerFromContext(ctx); l != nil {
+		l.output(calldepth+1, r)
+		return
+	}
+	outputTo(ctx, calldepth+1, r)
+}
+
+// outputContextf formats the message and writes it with the request ID to
+// the Logger in the context or the global outputs. The message is only
+// formatted if it will be written or kept by the flight recorder.
+func outputContextf(ctx context.Context, calldepth int, level uint, format string, v []interface{}) {
+	if l := LoggerFromContext(ctx); l != nil {
+		if !levelEnabled(level, atomic.LoadUint32(&l.level)) {
+			return
+		}
+	} else if !wanted(level, "") {
+		return
+	}
+	outputContext(ctx, calldepth+1, Record{Level: level, Message: fmt.Sprintf(format, v...), RequestID: RequestID(ctx)})
+}
@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	if _, err := NewLogger(NewMemoryLoggers(10)[:3]); err == nil {
+		t.Fatal("Expected error with too few logs")
+	}
+	l, err := NewLogger(NewMemoryLoggers(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetLogLevel(WarningLevel)
+	l.Debug("debug")
+	l.Info("info")
+	l.Warning("warning")
+	l.Error("error")
+	ErrorContext(WithLogger(context.Background(), l), "context error")
+
+	expected := []int{0, 0, 1, 2}
+	for level, n := range expected {
+		if got := len(l.Logs()[level].Entries()); got != n {
+			t.Fatalf("Expected %d entries at level %d but got %d", n, level, got)
+		}
+	}
+}
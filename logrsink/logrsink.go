@@ -0,0 +1,114 @@
+// Package logrsink is an adapter that routes logr.Logger output to the
+// logging package. Info messages at V-level 0 are logged at info level and
+// messages at higher V-levels at debug level. Errors are logged at error
+// level.
+package logrsink
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ExploratoryEngineering/logging"
+	"github.com/go-logr/logr"
+)
+
+// New returns a logr.Logger that writes to the logging package
+func New() logr.Logger {
+	return logr.New(&sink{})
+}
+
+// sink is the logr.LogSink implementation. The sink is copied for each
+// WithName/WithValues call so it is never modified after creation.
+type sink struct {
+	callDepth int
+	name      string
+	values    []interface{}
+}
+
+// Init receives the call depth added by logr.Logger
+func (s *sink) Init(info logr.RuntimeInfo) {
+	s.callDepth = info.CallDepth
+}
+
+// Enabled always returns true since the level check is done by the logging
+// package.
+func (s *sink) Enabled(level int) bool {
+	return true
+}
+
+func (s *sink) Info(level int, msg string, keysAndValues ...interface{}) {
+	l := logging.InfoLevel
+	if level > 0 {
+		l = logging.DebugLevel
+	}
+	// Skip Info and the logr.Logger frames
+	logging.Output(l, s.callDepth+2, s.format(msg, keysAndValues))
+}
+
+func (s *sink) Error(err error, msg string, keysAndValues ...interface{}) {
+	if err != nil {
+		keysAndValues = append([]interface{}{"error", err.Error()}, keysAndValues...)
+	}
+	logging.Output(logging.ErrorLevel, s.callDepth+2, s.format(msg, keysAndValues))
+}
+
+func (s *sink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	ret := *s
+	ret.values = append(append([]interface{}{}, s.values...), keysAndValues...)
+	return &ret
+}
+
+func (s *sink) WithName(name string) logr.LogSink {
+	ret := *s
+	if ret.name != "" {
+		ret.name += "/"
+	}
+	ret.name += name
+	return &ret
+}
+
+// WithCallDepth implements logr.CallDepthLogSink
+func (s *sink) WithCallDepth(depth int) logr.LogSink {
+	ret := *s
+	ret.callDepth += depth
+	return &ret
+}
+
+// format formats the message as "name: msg key=value key=value"
+func (s *sink) format(msg string, keysAndValues []interface{}) string {
+	var sb strings.Builder
+	if s.name != "" {
+		sb.WriteString(s.name + ": ")
+	}
+	sb.WriteString(msg)
+	kv := append(append([]interface{}{}, s.values...), keysAndValues...)
+	for i := 0; i < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		var value interface{} = "(MISSING)"
+		if i+1 < len(kv) {
+			value = kv[i+1]
+		}
+		v := fmt.Sprint(value)
+		if v == "" || strings.ContainsAny(v, " \t\"=") {
+			v = strconv.Quote(v)
+		}
+		sb.WriteString(" " + key + "=" + v)
+	}
+	return sb.String()
+}
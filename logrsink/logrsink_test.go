@@ -0,0 +1,37 @@
+package logrsink
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ExploratoryEngineering/logging"
+)
+
+func TestSink(t *testing.T) {
+	logs := logging.NewMemoryLoggers(10)
+	logging.EnableMemoryLogger(logs)
+	defer logging.EnableStderr(true)
+	logging.SetLogLevel(logging.DebugLevel)
+	defer logging.SetLogLevel(logging.WarningLevel)
+
+	l := New().WithName("store").WithValues("device", 42)
+	l.Info("created", "name", "my device")
+	l.V(1).Info("details")
+	l.Error(errors.New("gone"), "lookup failed")
+
+	info := logs[logging.InfoLevel].Entries()
+	if len(info) != 1 || strings.TrimSpace(info[0].Message) != `store: created device=42 name="my device"` {
+		t.Fatalf("Incorrect info entry: %+v", info)
+	}
+	if !strings.HasPrefix(info[0].Location, "logrsink_test.go:") {
+		t.Fatalf("Location should be the caller but is %s", info[0].Location)
+	}
+	if len(logs[logging.DebugLevel].Entries()) != 1 {
+		t.Fatal("Expected V(1) to be logged at debug level")
+	}
+	errs := logs[logging.ErrorLevel].Entries()
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "store: lookup failed device=42 error=gone") {
+		t.Fatalf("Incorrect error entry: %+v", errs)
+	}
+}
@@ -16,31 +16,15 @@ package logging
 //limitations under the License.
 //
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"log/syslog"
 	"os"
-	"sync/atomic"
+	"strings"
 )
 
-// Debug is the log file for debug logging. It is disabled by default. These
-// messages aren't really useful for anything except the developers.
-var debug = log.New(os.Stderr, " ", stderrFlags)
-
-// Info is the log for info-level logs. It is disabled by default. These messages
-// are typically "application created", "user registered", "device deleted" and
-// so on. They are useful when doing detailed monitoring of the service
-var info = log.New(os.Stderr, "  ", stderrFlags)
-
-// Warning is the log for warning-level logs, typically inconsistencies that
-// users might notice. There are *some* messages in this but not a lot.
-var warning = log.New(os.Stderr, " ", stderrFlags)
-
-// Error is the log for severe error logs; database errors, data inconsistencies,
-// failures and issues that require immediate action. There are very few issues
-// on this scale.
-var errlog = log.New(os.Stderr, " ", stderrFlags)
-
 // LogLevel is the log detail level
 
 const (
@@ -54,67 +38,76 @@ const (
 	ErrorLevel
 )
 
-var currentLevel uint32
-
 const syslogFlags = log.Lshortfile
 const stderrFlags = log.Ldate + log.Ltime + log.Lshortfile
 
 func init() {
+	currentSettings.Store(&settings{
+		outputs:    &outputs{},
+		level:      WarningLevel,
+		components: map[string]uint{},
+		samplers:   &[ErrorLevel]*sampler{},
+		theme:      DarkTheme,
+	})
 	EnableStderr(true)
-	SetLogLevel(WarningLevel)
 }
 
 // SetLogLevel sets the logging level
 func SetLogLevel(level uint) {
-	atomic.StoreUint32(&currentLevel, uint32(level))
-	//	currentLevel = level
+	updateSettings(func(s *settings) {
+		s.level = level
+	})
 }
 
-func setFlags(flags int) {
-	log.SetFlags(flags)
-	debug.SetFlags(flags)
-	info.SetFlags(flags)
-	warning.SetFlags(flags)
-	errlog.SetFlags(flags)
-}
-
-// EnableNamedSyslog enables sending logs to syslog with the given name.
+// EnableNamedSyslog enables sending logs to syslog with the given name. If
+// syslog can't be set up the error is logged and the current outputs are
+// kept. Use SetSyslogOutput to get the error.
 func EnableNamedSyslog(name string) {
-	errorLog, err := syslog.New(syslog.LOG_ERR|syslog.LOG_DAEMON, name)
-	if err != nil {
-		errlog.Printf("Unable to set up error syslog: %v", err)
-		return
+	if err := SetSyslogOutput(name); err != nil {
+		Error("Unable to set up syslog: %v", err)
 	}
-	warningLog, err := syslog.New(syslog.LOG_WARNING|syslog.LOG_DAEMON, name)
-	if err != nil {
-		errlog.Printf("Unable to set up warning syslog: %v", err)
-		return
-	}
-	infoLog, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, name)
+}
+
+// SetSyslogOutput enables sending logs to syslog with the given name. If
+// syslog can't be set up an error is returned and the current outputs are
+// kept.
+func SetSyslogOutput(name string) error {
+	writers, err := newSyslogWriters(name)
 	if err != nil {
-		errlog.Printf("Unable to set up info syslog: %v", err)
-		return
+		return err
 	}
-	debugLog, err := syslog.New(syslog.LOG_DEBUG|syslog.LOG_DAEMON, name)
-	if err != nil {
-		errlog.Printf("Unable to set up debug syslog: %v", err)
-		return
+	publish(syslogOutputs(writers))
+	return nil
+}
+
+// newSyslogWriters creates the syslog writers for each level. If one of the
+// writers can't be created the ones already created are closed.
+func newSyslogWriters(name string) ([]*syslog.Writer, error) {
+	priorities := []syslog.Priority{syslog.LOG_DEBUG, syslog.LOG_INFO, syslog.LOG_WARNING, syslog.LOG_ERR}
+	var writers []*syslog.Writer
+	for i, p := range priorities {
+		w, err := syslog.New(p|syslog.LOG_DAEMON, name)
+		if err != nil {
+			for _, w := range writers {
+				w.Close()
+			}
+			return nil, fmt.Errorf("unable to set up %s syslog: %v", strings.ToLower(LevelName(uint(i))), err)
+		}
+		writers = append(writers, w)
 	}
-	log.SetOutput(debugLog)
-	debug.SetOutput(debugLog)
-	info.SetOutput(infoLog)
-	warning.SetOutput(warningLog)
-	errlog.SetOutput(errorLog)
-	// Syslog includes time stamp so we just need the source file
-	setFlags(syslogFlags)
+	return writers, nil
+}
 
-	// Set text prefixes since that makes it easier to search the syslog
-	log.SetPrefix("")
-	debug.SetPrefix("")
-	info.SetPrefix("")
-	warning.SetPrefix("")
-	errlog.SetPrefix("")
-	SetLogLevel(uint(currentLevel))
+// syslogOutputs creates the outputs for the syslog writers
+func syslogOutputs(writers []*syslog.Writer) *outputs {
+	// Syslog includes time stamp so we just need the source file. There's no
+	// prefix since syslog has the priority.
+	o := &outputs{syslog: writers}
+	for i := range o.levels {
+		o.levels[i] = log.New(writers[i], "", syslogFlags)
+	}
+	o.std = log.New(writers[DebugLevel], "", syslogFlags)
+	return o
 }
 
 // EnableSyslog enables syslog logging with the name "congress"
@@ -122,101 +115,242 @@ func EnableSyslog() {
 	EnableNamedSyslog("congress")
 }
 
-// EnableMemoryLogger turns on logging to a memory logger.
+// EnableMemoryLogger turns on logging to a memory logger. If the logs are
+// invalid the error is printed on stderr and the current outputs are kept.
+// Use SetMemoryOutput to get the error.
 func EnableMemoryLogger(logs []*MemoryLogger) {
-	if len(logs) < int(ErrorLevel) {
-		fmt.Fprintf(os.Stderr, "Expected %d logs for memory log, got %d", ErrorLevel, len(logs))
-		return
+	if err := SetMemoryOutput(logs); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to set up memory log: %v\n", err)
 	}
-	log.SetOutput(logs[DebugLevel])
-	debug.SetOutput(logs[DebugLevel])
-	info.SetOutput(logs[InfoLevel])
-	warning.SetOutput(logs[WarningLevel])
-	errlog.SetOutput(logs[ErrorLevel])
-	setFlags(MemoryLoggerFlags)
-	log.SetPrefix("")
-	debug.SetPrefix("")
-	info.SetPrefix("")
-	warning.SetPrefix("")
-	errlog.SetPrefix("")
-}
-
-// ANSI escape codes for colored log lines. This will only show up on the stderr
-// logs. Printf statements on stdout will be broken but we don't do printf's do
-// we?
-const (
-	debugText   = "\x1b[0m"    // White
-	infoText    = "\x1b[34;1m" // Bright blue
-	warningText = "\x1b[33;1m" // Bright yellow
-	errorText   = "\x1b[31;1m" // Bright red
-	resetText   = "\x1b[0m"    // Reset
-)
+}
+
+// SetMemoryOutput turns on logging to memory loggers, one for each level as
+// returned by NewMemoryLoggers. If the logs are invalid an error is returned
+// and the current outputs are kept.
+func SetMemoryOutput(logs []*MemoryLogger) error {
+	o, err := memoryOutputs(logs)
+	if err != nil {
+		return err
+	}
+	publish(o)
+	return nil
+}
+
+// memoryOutputs creates the outputs for the memory logs
+func memoryOutputs(logs []*MemoryLogger) (*outputs, error) {
+	if len(logs) <= int(ErrorLevel) {
+		return nil, fmt.Errorf("expected %d logs for memory log, got %d", ErrorLevel+1, len(logs))
+	}
+	for i, l := range logs[:ErrorLevel+1] {
+		if l == nil {
+			return nil, fmt.Errorf("memory log for %s is nil", LevelName(uint(i)))
+		}
+	}
+	o := &outputs{std: log.New(logs[DebugLevel], "", MemoryLoggerFlags)}
+	for i := range o.levels {
+		o.levels[i] = log.New(logs[i], "", MemoryLoggerFlags)
+	}
+	return o, nil
+}
 
-// EnableStderr enables logging to stderr
+// resetText is the ANSI escape code that resets the colors. The colors are
+// set by the Theme.
+const resetText = "\x1b[0m"
+
+// EnableStderr enables logging to stderr. If plainText is false the log
+// lines are colored when stderr is a terminal that supports it. The NO_COLOR,
+// FORCE_COLOR and TERM environment variables are honoured. Emojis are used
+// as prefixes if the locale uses UTF-8. The prefixes and colors are set by
+// SetTheme.
 func EnableStderr(plainText bool) {
-	logwriter := os.Stderr
-
-	log.SetOutput(logwriter)
-	debug.SetOutput(logwriter)
-	info.SetOutput(logwriter)
-	warning.SetOutput(logwriter)
-	errlog.SetOutput(logwriter)
-
-	setFlags(stderrFlags)
-
-	if plainText {
-		// Use plain text logging
-		log.SetPrefix("LOG     ")
-		debug.SetPrefix("DEBUG   ")
-		info.SetPrefix("INFO    ")
-		warning.SetPrefix("WARNING ")
-		errlog.SetPrefix("ERROR   ")
-	} else {
-		// Use fancy emojis as prefix since this is something we'll look a *lot* at.
-		log.SetPrefix(debugText + "💡   ")
-		debug.SetPrefix(debugText + "    ")
-		info.SetPrefix(infoText + "ℹ️   ")
-		warning.SetPrefix(warningText + "⚠️   ")
-		errlog.SetPrefix(errorText + "🛑   ")
+	publish(stderrOutputs(plainText, getTheme()))
+}
+
+// stderrOutputs creates the outputs for stderr with the theme
+func stderrOutputs(plainText bool, theme Theme) *outputs {
+	color, unicode := false, false
+	if !plainText {
+		color, unicode = detectTerminal(os.Getenv, isTerminal(os.Stderr))
 	}
+	return themedOutputs(os.Stderr, theme, color, unicode)
+}
 
-	SetLogLevel(uint(currentLevel))
+// themedOutputs creates the outputs for the writer with the theme's prefixes.
+// Colored lines end with the reset code unless the theme only colors the
+// prefix.
+func themedOutputs(w io.Writer, theme Theme, color, unicode bool) *outputs {
+	if color && !theme.TagOnly {
+		w = &resetWriter{w: w}
+	}
+	return newOutputs(w, stderrFlags,
+		theme.prefix(theme.Debug, color, unicode),
+		theme.prefix(theme.Info, color, unicode),
+		theme.prefix(theme.Warning, color, unicode),
+		theme.prefix(theme.Error, color, unicode),
+		theme.prefix(theme.Log, color, unicode))
 }
 
 // Debug adds a debug-level log message to the log. If the log level is set
 // higher than DebugLevel the message will be discarded.
 func Debug(format string, v ...interface{}) {
-	level := atomic.LoadUint32(&currentLevel)
-	if uint(level) == DebugLevel {
-
-		debug.Output(2, fmt.Sprintf(format, v...))
-	}
+	outputf(DebugLevel, 2, "", format, v)
 }
 
 // Info adds an info-level log message to the log if the log level is set
 // to InfoLevel or lower.
 func Info(format string, v ...interface{}) {
-	level := atomic.LoadUint32(&currentLevel)
-	if uint(level) <= InfoLevel {
-		info.Output(2, fmt.Sprintf(format, v...))
-	}
+	outputf(InfoLevel, 2, "", format, v)
 }
 
 // Warning adds a warning-level log message if the log level is set to
 // WarningLevel or lower.
 func Warning(format string, v ...interface{}) {
-	level := atomic.LoadUint32(&currentLevel)
-	if uint(level) <= WarningLevel {
-		warning.Output(2, fmt.Sprintf(format, v...))
-	}
+	outputf(WarningLevel, 2, "", format, v)
 }
 
 // Error adds an error-level log message to the log.
 func Error(format string, v ...interface{}) {
-	errlog.Output(2, fmt.Sprintf(format, v...))
+	outputf(ErrorLevel, 2, "", format, v)
+}
+
+// output writes the message to the log for the level if the current log level
+// permits it. Errors are always written. The calldepth parameter works the
+// same way as for log.Output; 1 is the caller of output.
+func output(level uint, calldepth int, msg string) {
+	outputTo(nil, calldepth+1, Record{Level: level, Message: msg})
+}
+
+// outputf formats the message and writes it to the log for the level. The
+// message is only formatted if it will be written or kept by the flight
+// recorder so suppressed messages are cheap. The component is optional.
+func outputf(level uint, calldepth int, component string, format string, v []interface{}) {
+	if !wanted(level, component) {
+		return
+	}
+	outputTo(nil, calldepth+1, Record{Level: level, Message: fmt.Sprintf(format, v...), Component: component})
+}
+
+// wanted returns true if a message at the level for the component will be
+// written or kept by the flight recorder. It doesn't acquire the settings;
+// outputTo checks the level again with the settings it writes with.
+func wanted(level uint, component string) bool {
+	if level >= ErrorLevel || currentRecorder() != nil {
+		return true
+	}
+	s := currentSettings.Load().(*settings)
+	return levelEnabled(level, uint32(s.logLevel(component)))
+}
+
+// outputTo writes the record to the outputs if the log level permits it. The
+// level for the record's component is used if it has one. The record has the
+// level, the message and the details; the time and location are set when it
+// is written. Messages below the log level are kept by the flight recorder
+// (if it is enabled) and flushed before the next error. The context is used
+// by the flight recorder.
+func outputTo(ctx context.Context, calldepth int, r Record) {
+	s := acquireSettings()
+	defer s.release()
+	if !levelEnabled(r.Level, uint32(s.logLevel(r.Component))) {
+		if f := currentRecorder(); f != nil {
+			f.record(ctx, calldepth+1, r)
+		}
+		return
+	}
+	if f := currentRecorder(); f != nil && r.Level >= ErrorLevel {
+		f.flush(ctx, s.outputs)
+	}
+	s.write(calldepth+1, r)
+}
+
+// write writes the record to the log for the level without checking the
+// log level. The message might be dropped by the sampling. The calldepth
+// parameter is relative to the caller of write.
+func (s *settings) write(calldepth int, r Record) {
+	if !s.sampled(r.Level) {
+		return
+	}
+	if r.Level > ErrorLevel {
+		r.Level = ErrorLevel
+	}
+	emit(s.outputs.levels[r.Level], currentMirror(), calldepth+1, r)
+}
+
+// levelEnabled returns true if messages at the level should be logged when
+// the log level is set to current. Errors are always logged.
+func levelEnabled(level uint, current uint32) bool {
+	return level >= ErrorLevel || level >= uint(current)
+}
+
+// emit writes the record to the logger. Helper functions and the caller skip
+// are added to the call depth and the message is redacted before it is
+// written. The calldepth parameter is relative to the caller of emit. If the
+// logger's output is a RecordWriter it gets the record with the caller
+// metadata, otherwise it gets the formatted text. The mirror (if any) gets a
+// copy of the record.
+func emit(l *log.Logger, mirror RecordWriter, calldepth int, r Record) {
+	depth := callerDepth(calldepth)
+	r.redact()
+	w, isRecordWriter := l.Writer().(RecordWriter)
+	if !isRecordWriter && mirror == nil {
+		l.Output(depth+1, r.text())
+		return
+	}
+	r.setCaller(depth)
+	if isRecordWriter {
+		w.WriteRecord(&r)
+	} else {
+		l.Output(depth+1, r.text())
+	}
+	if mirror != nil {
+		mirror.WriteRecord(&r)
+	}
+}
+
+// LevelName returns the name of the log level, ie "DEBUG", "INFO", "WARNING"
+// or "ERROR".
+func LevelName(level uint) string {
+	switch level {
+	case DebugLevel:
+		return "DEBUG"
+	case InfoLevel:
+		return "INFO"
+	case WarningLevel:
+		return "WARNING"
+	case ErrorLevel:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL%d", level)
+}
+
+// ParseLevel returns the log level with the name. The names are the ones
+// returned by LevelName and are case insensitive.
+func ParseLevel(name string) (uint, error) {
+	for level := DebugLevel; level <= ErrorLevel; level++ {
+		if strings.EqualFold(name, LevelName(level)) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// Flush flushes the log outputs that support it, ie outputs that have either
+// a Flush() or a Sync() method. Errors are ignored since there's nowhere to
+// report them.
+func Flush() {
+	s := acquireSettings()
+	defer s.release()
+	for _, l := range append(s.outputs.levels[:], s.outputs.std) {
+		switch f := l.Writer().(type) {
+		case interface{ Flush() error }:
+			f.Flush()
+		case interface{ Sync() error }:
+			f.Sync()
+		}
+	}
 }
 
-// ResetColors prints the ANSI color reset code
+// ResetColors prints the ANSI color reset code. This isn't required when
+// logging to stderr since every colored line ends with the reset code.
 func ResetColors() {
 	fmt.Print(resetText)
 }
@@ -24,6 +24,24 @@ var levels = []uint{
 	ErrorLevel,
 }
 
+// setLogLevel sets the global log level for the test and restores the
+// previous level when the test is done.
+func setLogLevel(t *testing.T, level uint) {
+	old := currentSettings.Load().(*settings).level
+	SetLogLevel(level)
+	t.Cleanup(func() { SetLogLevel(old) })
+}
+
+// setMemoryOutput logs to the memory logs for the test and restores the
+// previous outputs when the test is done.
+func setMemoryOutput(t *testing.T, logs []*MemoryLogger) {
+	old := currentSettings.Load().(*settings).outputs
+	if err := SetMemoryOutput(logs); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { publish(old) })
+}
+
 func TestStderrLogging(t *testing.T) {
 	EnableStderr(true)
 
@@ -69,3 +87,47 @@ func TestMemoryLoggging(t *testing.T) {
 		Error("This is error level (round %d)", i)
 	}
 }
+
+func TestSetOutputErrors(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	if err := SetMemoryOutput(logs); err != nil {
+		t.Fatal(err)
+	}
+	defer EnableStderr(true)
+
+	if err := SetMemoryOutput(logs[:3]); err == nil {
+		t.Fatal("Expected error with 3 logs")
+	}
+	if err := SetMemoryOutput([]*MemoryLogger{logs[0], nil, logs[2], logs[3]}); err == nil {
+		t.Fatal("Expected error with nil log")
+	}
+	EnableMemoryLogger(nil)
+	Error("Still logging to memory")
+	if logs[ErrorLevel].NumEntries() != 1 {
+		t.Fatal("Invalid memory logs should not change the outputs")
+	}
+
+	if err := SetSyslogOutput("logging-test"); err != nil {
+		// No syslog available; the memory log should still be in use
+		Error("Still logging to memory")
+		if logs[ErrorLevel].NumEntries() != 2 {
+			t.Fatalf("Failed syslog setup should not change the outputs: %v", err)
+		}
+		return
+	}
+	if len(currentSettings.Load().(*settings).outputs.syslog) != 4 {
+		t.Fatal("Expected syslog writers to be kept")
+	}
+	EnableMemoryLogger(logs)
+	if currentSettings.Load().(*settings).outputs.syslog != nil {
+		t.Fatal("Expected syslog writers to be replaced when the output changes")
+	}
+}
+
+func BenchmarkDebugDisabled(b *testing.B) {
+	SetLogLevel(WarningLevel)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Debug("Suppressed %d", i)
+	}
+}
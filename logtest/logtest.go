@@ -0,0 +1,133 @@
+// Package logtest captures log entries in tests. Each test gets its own
+// logging.Logger instance so tests can run in parallel. Code under test must
+// either use the Logger directly or use the context-aware log functions
+// (logging.InfoContext and so on) with the context returned by Context.
+//
+// The captured entries are written to the test log if the test fails.
+package logtest
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ExploratoryEngineering/logging"
+)
+
+// MaxEntries is the number of entries kept for each level
+const MaxEntries = 1000
+
+// Capture holds the captured log entries for a single test
+type Capture struct {
+	t      testing.TB
+	logs   []*logging.MemoryLogger
+	logger *logging.Logger
+}
+
+// New creates a new Capture for the test. The captured entries are written
+// to the test log when the test completes if it has failed.
+func New(t testing.TB) *Capture {
+	t.Helper()
+	logs := logging.NewMemoryLoggers(MaxEntries)
+	logger, err := logging.NewLogger(logs)
+	if err != nil {
+		t.Fatalf("Unable to create logger: %v", err)
+	}
+	c := &Capture{t: t, logs: logs, logger: logger}
+	t.Cleanup(func() {
+		if t.Failed() {
+			c.Dump()
+		}
+	})
+	return c
+}
+
+// Logger returns the Logger that captures the entries
+func (c *Capture) Logger() *logging.Logger {
+	return c.logger
+}
+
+// Context returns a copy of the parent context with the Logger set
+func (c *Capture) Context(parent context.Context) context.Context {
+	return logging.WithLogger(parent, c.logger)
+}
+
+// Entries returns all of the captured entries in the order they were logged
+func (c *Capture) Entries() []logging.LogEntry {
+	return c.logs[0].Merge(c.logs[1:]...)
+}
+
+// Find returns the captured entries at the level where the message matches
+// the regular expression.
+func (c *Capture) Find(level uint, expr string) []logging.LogEntry {
+	c.t.Helper()
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		c.t.Fatalf("Invalid expression %q: %v", expr, err)
+	}
+	var ret []logging.LogEntry
+	for _, e := range c.Entries() {
+		if e.Level == level && re.MatchString(e.Message) {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// AssertContains fails the test if there's no entry at the level where the
+// message matches the regular expression.
+func (c *Capture) AssertContains(level uint, expr string) {
+	c.t.Helper()
+	if len(c.Find(level, expr)) == 0 {
+		c.t.Errorf("Expected a %s entry matching %q", logging.LevelName(level), expr)
+	}
+}
+
+// AssertNotContains fails the test if there's an entry at the level where the
+// message matches the regular expression.
+func (c *Capture) AssertNotContains(level uint, expr string) {
+	c.t.Helper()
+	if found := c.Find(level, expr); len(found) > 0 {
+		c.t.Errorf("Expected no %s entries matching %q but found %d", logging.LevelName(level), expr, len(found))
+	}
+}
+
+// AssertNone fails the test if there are entries at the level or above. Use
+// AssertNone(logging.WarningLevel) to check that there are no warnings or
+// errors.
+func (c *Capture) AssertNone(level uint) {
+	c.t.Helper()
+	count := 0
+	for _, e := range c.Entries() {
+		if e.Level >= level {
+			count++
+		}
+	}
+	if count > 0 {
+		c.t.Errorf("Expected no entries at %s or above but found %d", logging.LevelName(level), count)
+	}
+}
+
+// Dump writes the captured entries to the test log
+func (c *Capture) Dump() {
+	c.t.Helper()
+	for _, e := range c.Entries() {
+		c.t.Logf("%-7s %s %s: %s", logging.LevelName(e.Level), e.Time.Format("15:04:05.000"), e.Location, strings.TrimSpace(e.Message))
+	}
+}
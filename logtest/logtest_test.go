@@ -0,0 +1,80 @@
+package logtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ExploratoryEngineering/logging"
+)
+
+// fakeT records failures and log output instead of failing the test
+type fakeT struct {
+	testing.TB
+	failed   bool
+	output   []string
+	cleanups []func()
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failed = true
+}
+
+func (f *fakeT) Failed() bool {
+	return f.failed
+}
+
+func (f *fakeT) Logf(format string, args ...interface{}) {
+	f.output = append(f.output, format)
+}
+
+func (f *fakeT) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+func TestCaptureLogger(t *testing.T) {
+	t.Parallel()
+	c := New(t)
+	c.Logger().Info("Device %d created", 42)
+	c.Logger().Err(errors.New("database is gone"))
+
+	c.AssertContains(logging.InfoLevel, `Device \d+ created`)
+	c.AssertContains(logging.ErrorLevel, "database")
+	c.AssertNotContains(logging.WarningLevel, ".*")
+	c.AssertNone(logging.ErrorLevel + 1)
+	if len(c.Entries()) != 2 {
+		t.Fatalf("Expected 2 entries but got %d", len(c.Entries()))
+	}
+}
+
+func TestCaptureContext(t *testing.T) {
+	t.Parallel()
+	c := New(t)
+	ctx := c.Context(context.Background())
+	logging.WarningContext(ctx, "Something is off")
+
+	found := c.Find(logging.WarningLevel, "off")
+	if len(found) != 1 || !strings.HasPrefix(found[0].Location, "logtest_test.go:") {
+		t.Fatalf("Expected entry with location in test: %+v", found)
+	}
+}
+
+func TestDumpOnFailure(t *testing.T) {
+	t.Parallel()
+	f := &fakeT{TB: t}
+	c := New(f)
+	c.Logger().Warning("A warning")
+	c.AssertNone(logging.WarningLevel)
+	if !f.failed {
+		t.Fatal("Expected assertion to fail")
+	}
+	for _, fn := range f.cleanups {
+		fn()
+	}
+	if len(f.output) != 1 {
+		t.Fatalf("Expected captured entries to be dumped but got %v", f.output)
+	}
+}
@@ -0,0 +1,334 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits for the number of entries returned by the log view handler
+const (
+	DefaultLogViewLimit = 100
+	MaxLogViewLimit     = 1000
+)
+
+// NewLogViewHandler returns a http.Handler for browsing the memory logs, one
+// for each level as returned by NewMemoryLoggers or MemoryStore.Logs. The
+// handler serves a HTML page with the same view as the TerminalLogger. The
+// entries are returned as JSON if the format=json parameter is set or the
+// client accepts application/json. These parameters filter the entries:
+//
+//	level      Comma-separated level names, ie "error,warning"
+//	since      The start time (RFC 3339) or a duration, ie "5m" for the last
+//	           five minutes
+//	until      The end time (RFC 3339) or a duration
+//	text       A substring of the message
+//	location   A prefix of the location, ie "store.go"
+//	component  The component name
+//	request    The request ID
+//	after      Only entries with a higher sequence number
+//	before     Only entries with a lower sequence number
+//	limit      The maximum number of entries (default 100, max 1000)
+//	order      "desc" for the newest entries first
+//
+// The response has the entries and the sequence number of the last entry.
+// Use that as the after (or before for descending order) parameter to get
+// the next page. An empty page returns the after (or before) parameter as
+// the last entry so polling can continue from it. The handler doesn't do
+// any authentication; wrap it in a handler that does if the logs contain
+// sensitive information.
+func NewLogViewHandler(logs []*MemoryLogger) http.Handler {
+	return &logViewHandler{logs: logs}
+}
+
+type logViewHandler struct {
+	logs []*MemoryLogger
+}
+
+// logViewResponse is the JSON response from the log view handler
+type logViewResponse struct {
+	Entries []logViewEntry `json:"entries"`
+	Last    uint64         `json:"last"`
+}
+
+// logViewEntry is the JSON representation of a LogEntry
+type logViewEntry struct {
+	Seq       uint64         `json:"seq"`
+	Time      time.Time      `json:"time"`
+	Level     string         `json:"level"`
+	Location  string         `json:"location"`
+	File      string         `json:"file,omitempty"`
+	Line      int            `json:"line,omitempty"`
+	Function  string         `json:"function,omitempty"`
+	Goroutine uint64         `json:"goroutine,omitempty"`
+	Message   string         `json:"message"`
+	RequestID string         `json:"requestId,omitempty"`
+	Component string         `json:"component,omitempty"`
+	Causes    []logViewCause `json:"causes,omitempty"`
+	Stack     []logViewFrame `json:"stack,omitempty"`
+}
+
+type logViewCause struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type logViewFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+func (h *logViewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Query().Get("format") != "json" && !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, logViewPage)
+		return
+	}
+	q, err := parseLogViewQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// An empty page keeps the position the client sent so polling with
+	// after=last doesn't start over from the oldest entry.
+	resp := logViewResponse{Entries: []logViewEntry{}, Last: q.After}
+	if q.Reverse {
+		resp.Last = q.Before
+	}
+	for _, e := range QueryLogs(q, h.logs...) {
+		resp.Entries = append(resp.Entries, newLogViewEntry(e))
+		resp.Last = e.Seq
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseLogViewQuery creates the query from the request parameters
+func parseLogViewQuery(r *http.Request) (Query, error) {
+	params := r.URL.Query()
+	q := Query{
+		Text:      params.Get("text"),
+		Location:  params.Get("location"),
+		Component: params.Get("component"),
+		Limit:     DefaultLogViewLimit,
+		Reverse:   params.Get("order") == "desc",
+	}
+	if id := params.Get("request"); id != "" {
+		q.Fields = map[string]string{"request": id}
+	}
+	if levels := params.Get("level"); levels != "" {
+		for _, name := range strings.Split(levels, ",") {
+			level, err := ParseLevel(strings.TrimSpace(name))
+			if err != nil {
+				return q, err
+			}
+			q.Levels = append(q.Levels, level)
+		}
+	}
+	var err error
+	if q.Since, err = parseLogViewTime(params.Get("since")); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseLogViewTime(params.Get("until")); err != nil {
+		return q, err
+	}
+	for name, v := range map[string]*uint64{"after": &q.After, "before": &q.Before} {
+		if s := params.Get(name); s != "" {
+			if *v, err = strconv.ParseUint(s, 10, 64); err != nil {
+				return q, fmt.Errorf("invalid %s parameter: %q", name, s)
+			}
+		}
+	}
+	if s := params.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 {
+			return q, fmt.Errorf("invalid limit parameter: %q", s)
+		}
+	}
+	if q.Limit > MaxLogViewLimit {
+		q.Limit = MaxLogViewLimit
+	}
+	return q, nil
+}
+
+// parseLogViewTime parses a time stamp or a duration relative to now
+func parseLogViewTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return t, fmt.Errorf("invalid time: %q", s)
+	}
+	return t, nil
+}
+
+func newLogViewEntry(e LogEntry) logViewEntry {
+	ret := logViewEntry{
+		Seq:       e.Seq,
+		Time:      e.Time,
+		Level:     LevelName(e.Level),
+		Location:  e.Location,
+		File:      e.File,
+		Line:      e.Line,
+		Function:  e.Function,
+		Goroutine: e.Goroutine,
+		Message:   e.Message,
+		RequestID: e.RequestID,
+		Component: e.Component,
+	}
+	for _, c := range e.Causes {
+		ret.Causes = append(ret.Causes, logViewCause{Type: c.Type, Message: c.Message})
+	}
+	for _, f := range e.Stack {
+		ret.Stack = append(ret.Stack, logViewFrame{Function: f.Function, File: f.File, Line: f.Line})
+	}
+	return ret
+}
+
+// logViewPage is the HTML page for the log view. It mirrors the
+// TerminalLogger with toggles for each level and polls for new entries.
+const logViewPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Logs</title>
+<style>
+body { margin: 0; background: #000; color: #ddd; font: 13px monospace; }
+header { position: sticky; top: 0; background: #00a; color: #ff5; padding: 4px 8px; font-weight: bold; display: flex; gap: 8px; align-items: center; }
+header span { flex: 1; }
+label { padding: 0 6px; cursor: pointer; font-weight: normal; }
+label.off { background: #000 !important; color: #00a !important; }
+input[type=text] { font: inherit; }
+.l-ERROR { background: #a00; color: #fff; }
+.l-WARNING { background: #aa0; color: #000; }
+.l-INFO { background: #0aa; color: #000; }
+.l-DEBUG { background: #aaa; color: #000; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: 1px 8px; vertical-align: top; white-space: pre-wrap; }
+td.time, td.loc { white-space: nowrap; color: #888; }
+tr.DEBUG td.msg { color: #fff; }
+tr.INFO td.msg { color: #55f; font-weight: bold; }
+tr.WARNING td.msg { color: #ff5; font-weight: bold; }
+tr.ERROR td.msg { color: #f55; font-weight: bold; }
+.details { color: #888; font-weight: normal; }
+</style>
+</head>
+<body>
+<header>
+<span>Logs</span>
+<label class="l-ERROR"><input type="checkbox" data-level="ERROR" checked> E</label>
+<label class="l-WARNING"><input type="checkbox" data-level="WARNING" checked> W</label>
+<label class="l-INFO"><input type="checkbox" data-level="INFO" checked> I</label>
+<label class="l-DEBUG"><input type="checkbox" data-level="DEBUG" checked> D</label>
+<input type="text" id="text" placeholder="Filter">
+</header>
+<table><tbody id="entries"></tbody></table>
+<script>
+// last is the sequence number of the last entry shown. It is 0 until the
+// first entry is shown; loaded is set once the first page has been fetched.
+// The generation is increased on every reload so responses for the old
+// filter are dropped.
+var last = 0, loaded = false, generation = 0;
+var tbody = document.getElementById("entries");
+
+function params() {
+	var levels = [];
+	document.querySelectorAll("input[data-level]").forEach(function (c) {
+		c.parentNode.className = "l-" + c.dataset.level + (c.checked ? "" : " off");
+		if (c.checked) levels.push(c.dataset.level);
+	});
+	var p = new URLSearchParams({format: "json", level: levels.join(","), text: document.getElementById("text").value});
+	return levels.length ? p : null;
+}
+
+function cell(row, cls, text) {
+	var td = row.insertCell();
+	td.className = cls;
+	td.textContent = text;
+	return td;
+}
+
+function add(e) {
+	var row = tbody.insertRow();
+	row.className = e.level;
+	cell(row, "time", new Date(e.time).toLocaleTimeString());
+	cell(row, "loc", e.location);
+	var msg = cell(row, "msg", e.message.trim());
+	var details = (e.causes || []).map(function (c) { return "caused by " + c.type + ": " + c.message; })
+		.concat((e.stack || []).map(function (f) { return "at " + f.function + " (" + f.file + ":" + f.line + ")"; }));
+	if (e.requestId) details.unshift("request " + e.requestId);
+	if (details.length) {
+		var d = document.createElement("div");
+		d.className = "details";
+		d.textContent = details.join("\n");
+		msg.appendChild(d);
+	}
+}
+
+function reload() {
+	tbody.textContent = "";
+	last = 0;
+	loaded = false;
+	var current = ++generation;
+	var p = params();
+	if (!p) return;
+	p.set("order", "desc");
+	p.set("limit", "1000");
+	fetch("?" + p).then(function (r) { return r.json(); }).then(function (resp) {
+		if (current != generation) return;
+		resp.entries.reverse().forEach(add);
+		if (resp.entries.length) last = resp.entries[resp.entries.length - 1].seq;
+		loaded = true;
+		window.scrollTo(0, document.body.scrollHeight);
+	});
+}
+
+function poll() {
+	var p = params();
+	if (!p || !loaded) return;
+	var current = generation;
+	p.set("after", last);
+	fetch("?" + p).then(function (r) { return r.json(); }).then(function (resp) {
+		if (current != generation || resp.entries.length == 0 || resp.entries[0].seq <= last) return;
+		var bottom = window.innerHeight + window.scrollY >= document.body.scrollHeight - 5;
+		resp.entries.forEach(add);
+		if (resp.last) last = resp.last;
+		if (bottom) window.scrollTo(0, document.body.scrollHeight);
+	});
+}
+
+document.querySelectorAll("input").forEach(function (i) { i.addEventListener("change", reload); });
+reload();
+setInterval(poll, 1000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func getLogView(t *testing.T, h http.Handler, query string) logViewResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?format=json&"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for %q but got %d: %s", query, rec.Code, rec.Body.String())
+	}
+	var resp logViewResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestLogViewHandler(t *testing.T) {
+	logger, err := NewLogger(NewMemoryLoggers(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		logger.Debug("debug %d", i)
+		logger.Error("error %d", i)
+	}
+	h := NewLogViewHandler(logger.Logs())
+
+	resp := getLogView(t, h, "")
+	if len(resp.Entries) != 20 || resp.Last != resp.Entries[19].Seq {
+		t.Fatalf("Expected all entries but got %d (last %d)", len(resp.Entries), resp.Last)
+	}
+	if e := resp.Entries[1]; e.Level != "ERROR" || e.Message != "error 0" || !strings.HasPrefix(e.Location, "logview_test.go:") {
+		t.Fatalf("Unexpected entry: %+v", e)
+	}
+
+	resp = getLogView(t, h, "level=error&text=error+1")
+	if len(resp.Entries) != 1 || resp.Entries[0].Message != "error 1" {
+		t.Fatalf("Expected one filtered entry but got %+v", resp.Entries)
+	}
+	if resp = getLogView(t, h, "since=1h&until=2006-01-02T15:04:05Z"); len(resp.Entries) != 0 {
+		t.Fatalf("Expected no entries in time range but got %d", len(resp.Entries))
+	}
+
+	// Page through the entries
+	var seen []string
+	for after := uint64(0); ; {
+		resp = getLogView(t, h, fmt.Sprintf("level=debug&limit=3&after=%d", after))
+		if len(resp.Entries) == 0 {
+			break
+		}
+		for _, e := range resp.Entries {
+			seen = append(seen, e.Message)
+		}
+		after = resp.Last
+	}
+	if len(seen) != 10 || seen[0] != "debug 0" || seen[9] != "debug 9" {
+		t.Fatalf("Unexpected pages: %v", seen)
+	}
+	if last := resp.Last; last == 0 {
+		t.Fatal("An empty page should keep the position")
+	} else if resp = getLogView(t, h, fmt.Sprintf("level=debug&after=%d", last)); len(resp.Entries) != 0 || resp.Last != last {
+		t.Fatalf("Expected an empty page at %d but got %d entries (last %d)", last, len(resp.Entries), resp.Last)
+	}
+	resp = getLogView(t, h, "level=debug&limit=2&order=desc")
+	if len(resp.Entries) != 2 || resp.Entries[0].Message != "debug 9" {
+		t.Fatalf("Expected newest entries first but got %+v", resp.Entries)
+	}
+	resp = getLogView(t, h, fmt.Sprintf("level=debug&order=desc&before=%d", resp.Last))
+	if len(resp.Entries) != 8 || resp.Entries[0].Message != "debug 7" {
+		t.Fatalf("Expected the next page but got %+v", resp.Entries)
+	}
+
+	for _, query := range []string{"level=trace", "since=yesterday", "after=-1", "limit=0"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?format=json&"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q but got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestLogViewPage(t *testing.T) {
+	h := NewLogViewHandler(NewMemoryLoggers(10))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || !strings.Contains(string(body), "<html>") {
+		t.Fatalf("Expected HTML page but got %s", rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Type") != "application/json" || !strings.Contains(rec.Body.String(), `"entries":[]`) {
+		t.Fatalf("Expected empty JSON response but got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405 but got %d", rec.Code)
+	}
+}
@@ -0,0 +1,314 @@
+//go:build !windows
+
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// MappedSlotSize is the size of each entry in a mapped log. Longer entries
+// are truncated.
+const MappedSlotSize = 1024
+
+// Layout of the mapped log file. The file header is the magic string followed
+// by the slot size and the number of slots. Each slot has a fixed header
+// followed by the file name, the function name, the message and the encoded
+// details (causes, stack trace, request ID and component) of records:
+//
+//	0  CRC-32 of the rest of the slot header and the strings
+//	4  Length of the strings
+//	8  Sequence number
+//	16 Time (Unix nanoseconds)
+//	24 Line
+//	28 Level
+//	29 Flags (1 for text entries)
+//	30 Length of the file name
+//	32 Length of the function name
+//	34 Length of the message
+//	36 Goroutine ID
+const (
+	mappedMagic      = "EEMMAP02"
+	mappedHeaderSize = 64
+	slotHeaderSize   = 44
+)
+
+var mappedByteOrder = binary.LittleEndian
+
+// MappedLogger is a log backed by a memory-mapped file. The entries are
+// written to a ring of fixed size slots in the file with a checksum for each
+// entry. The operating system writes the pages to the file even if the
+// process is killed so the last entries can be recovered with
+// RecoverMappedLog when the process is restarted. Entries are not guaranteed
+// to survive an operating system crash or power loss.
+//
+// The MappedLogger can be used as an output on its own or as a mirror for the
+// regular outputs with SetMirror.
+type MappedLogger struct {
+	mutex sync.Mutex
+	file  *os.File
+	data  []byte
+	slots int
+	seq   uint64
+	level uint // The level for text entries
+}
+
+// OpenMappedLogger opens or creates the mapped log file with room for the
+// number of entries. An existing file with the same number of entries is
+// reused and new entries are added after the existing ones. Text written to
+// the logger (not records) is logged at the level.
+func OpenMappedLogger(filename string, entries int, level uint) (*MappedLogger, error) {
+	if entries < 1 {
+		return nil, errors.New("mapped log must have at least one entry")
+	}
+	size := mappedHeaderSize + entries*MappedSlotSize
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() != int64(size) {
+		// Start over if the size has changed
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := f.Truncate(int64(size)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to map log file: %v", err)
+	}
+	m := &MappedLogger{file: f, data: data, slots: entries, level: level}
+	if string(data[:len(mappedMagic)]) == mappedMagic {
+		for _, s := range m.validSlots() {
+			if s.seq > m.seq {
+				m.seq = s.seq
+			}
+		}
+	} else {
+		for i := range data {
+			data[i] = 0
+		}
+	}
+	copy(data, mappedMagic)
+	mappedByteOrder.PutUint32(data[8:], MappedSlotSize)
+	mappedByteOrder.PutUint32(data[12:], uint32(entries))
+	return m, nil
+}
+
+// Write is the io.Writer implementation. The text is parsed when the log is
+// recovered.
+func (m *MappedLogger) Write(p []byte) (int, error) {
+	r := Record{Time: time.Now(), Level: m.level}
+	if err := m.write(&r, p, true); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteRecord is the RecordWriter implementation
+func (m *MappedLogger) WriteRecord(r *Record) error {
+	return m.write(r, nil, false)
+}
+
+func (m *MappedLogger) write(r *Record, text []byte, isText bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.data == nil {
+		return errors.New("mapped log is closed")
+	}
+	m.seq++
+	start := mappedHeaderSize + int((m.seq-1)%uint64(m.slots))*MappedSlotSize
+	slot := m.data[start : start+MappedSlotSize]
+
+	// The strings are truncated to fit the slot; the details are truncated
+	// first, then the message.
+	room := MappedSlotSize - slotHeaderSize
+	file := truncate(r.File, room)
+	function := truncate(r.Function, room-len(file))
+	n := copy(slot[slotHeaderSize:], file)
+	n += copy(slot[slotHeaderSize+n:], function)
+	message := r.Message
+	if isText {
+		message = string(text)
+	}
+	message = truncate(message, room-n)
+	n += copy(slot[slotHeaderSize+n:], message)
+	if !isText {
+		n += copy(slot[slotHeaderSize+n:], r.appendDetails(nil))
+	}
+
+	mappedByteOrder.PutUint32(slot[4:], uint32(n))
+	mappedByteOrder.PutUint64(slot[8:], m.seq)
+	mappedByteOrder.PutUint64(slot[16:], uint64(r.Time.UnixNano()))
+	mappedByteOrder.PutUint32(slot[24:], uint32(r.Line))
+	slot[28] = byte(r.Level)
+	slot[29] = 0
+	if isText {
+		slot[29] = 1
+	}
+	mappedByteOrder.PutUint16(slot[30:], uint16(len(file)))
+	mappedByteOrder.PutUint16(slot[32:], uint16(len(function)))
+	mappedByteOrder.PutUint16(slot[34:], uint16(len(message)))
+	mappedByteOrder.PutUint64(slot[36:], r.Goroutine)
+	mappedByteOrder.PutUint32(slot, crc32.ChecksumIEEE(slot[4:slotHeaderSize+n]))
+	return nil
+}
+
+// truncate returns the longest prefix of s that is at most max bytes. UTF-8
+// sequences are not split.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// Close unmaps and closes the file. The entries are kept in the file.
+func (m *MappedLogger) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(m.data)
+	m.data = nil
+	if cerr := m.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// validSlots returns the slots with valid checksums, oldest first
+func (m *MappedLogger) validSlots() []memorySlot {
+	return parseMappedSlots(m.data, m.slots)
+}
+
+// parseMappedSlots returns the slots in the data with valid checksums,
+// oldest first. Slots that were partially written when the process crashed
+// have invalid checksums and are skipped.
+func parseMappedSlots(data []byte, slots int) []memorySlot {
+	var ret []memorySlot
+	for i := 0; i < slots; i++ {
+		start := mappedHeaderSize + i*MappedSlotSize
+		slot := data[start : start+MappedSlotSize]
+		n := int(mappedByteOrder.Uint32(slot[4:]))
+		if n > MappedSlotSize-slotHeaderSize || mappedByteOrder.Uint64(slot[8:]) == 0 {
+			continue
+		}
+		if crc32.ChecksumIEEE(slot[4:slotHeaderSize+n]) != mappedByteOrder.Uint32(slot) {
+			continue
+		}
+		fileLen := int(mappedByteOrder.Uint16(slot[30:]))
+		functionLen := int(mappedByteOrder.Uint16(slot[32:]))
+		messageEnd := fileLen + functionLen + int(mappedByteOrder.Uint16(slot[34:]))
+		if messageEnd > n {
+			continue
+		}
+		strings := slot[slotHeaderSize : slotHeaderSize+n]
+		s := memorySlot{
+			seq:    mappedByteOrder.Uint64(slot[8:]),
+			isText: slot[29]&1 != 0,
+			record: Record{
+				Time:      time.Unix(0, int64(mappedByteOrder.Uint64(slot[16:]))),
+				Line:      int(mappedByteOrder.Uint32(slot[24:])),
+				Level:     uint(slot[28]),
+				Goroutine: mappedByteOrder.Uint64(slot[36:]),
+				File:      string(strings[:fileLen]),
+				Function:  string(strings[fileLen : fileLen+functionLen]),
+				Message:   string(strings[fileLen+functionLen : messageEnd]),
+			},
+		}
+		if !s.isText {
+			s.record.readDetails(strings[messageEnd:])
+		}
+		ret = append(ret, s)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].seq < ret[j].seq })
+	return ret
+}
+
+// readMappedLog reads the valid slots in a mapped log file
+func readMappedLog(filename string) ([]memorySlot, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < mappedHeaderSize || string(data[:len(mappedMagic)]) != mappedMagic {
+		return nil, errors.New("not a mapped log file")
+	}
+	slotSize := int(mappedByteOrder.Uint32(data[8:]))
+	slots := int(mappedByteOrder.Uint32(data[12:]))
+	if slotSize != MappedSlotSize || len(data) != mappedHeaderSize+slots*MappedSlotSize {
+		return nil, errors.New("invalid mapped log file size")
+	}
+	return parseMappedSlots(data, slots), nil
+}
+
+// RecoverMappedLog returns the entries in a mapped log file, oldest first.
+// Use this when the process starts to see the entries that were logged
+// before it was restarted or killed. The sequence numbers are the ones from
+// the file.
+func RecoverMappedLog(filename string) ([]LogEntry, error) {
+	slots, err := readMappedLog(filename)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]LogEntry, len(slots))
+	for i := range slots {
+		ret[i] = slots[i].entry()
+	}
+	return ret, nil
+}
+
+// RestoreMappedLog adds the entries in a mapped log file to the memory logs
+// so they can be viewed with the TerminalLogger or saved with SaveSnapshot.
+// See ReadSnapshot for how the entries are added to the logs.
+func RestoreMappedLog(filename string, logs ...*MemoryLogger) error {
+	if len(logs) == 0 {
+		return errors.New("no memory logs to restore to")
+	}
+	slots, err := readMappedLog(filename)
+	if err != nil {
+		return err
+	}
+	for _, s := range slots {
+		restoreTarget(logs, s.record.Level).restore(s)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+//go:build !windows
+
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMappedLogger(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "crash.log")
+	m, err := OpenMappedLogger(filename, 5, InfoLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetMirror(m)
+	defer SetMirror(nil)
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	for i := 0; i < 4; i++ {
+		Error("error %d", i)
+	}
+	fmt.Fprintf(m, "main.go:10: text entry\n")
+	Error("long message %s", strings.Repeat("é", MappedSlotSize))
+
+	// The file is read without closing the logger, like after a crash
+	entries, err := RecoverMappedLog(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("Expected 5 entries but got %d", len(entries))
+	}
+	if entries[0].Message != "error 1" || entries[0].Level != ErrorLevel || !strings.HasPrefix(entries[0].Location, "mapped_test.go:") {
+		t.Fatalf("Unexpected first entry: %+v", entries[0])
+	}
+	if entries[3].Location != "main.go:10" || entries[3].Level != InfoLevel {
+		t.Fatalf("Unexpected text entry: %+v", entries[3])
+	}
+	if !strings.HasPrefix(entries[4].Message, "long message ééé") || !utf8.ValidString(entries[4].Message) || entries[4].Function == "" {
+		t.Fatalf("Unexpected truncated entry: %+v", entries[4])
+	}
+	for max, expected := range map[int]string{0: "", 1: "a", 2: "a", 3: "aé", 4: "aé"} {
+		if s := truncate("aé", max); s != expected {
+			t.Fatalf("Expected %q when truncating to %d bytes but got %q", expected, max, s)
+		}
+	}
+	if len(logs[ErrorLevel].Entries()) != 5 {
+		t.Fatal("The outputs should get the entries as well")
+	}
+	SetMirror(nil)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the slot with "error 2"
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := strings.Index(string(data), "error 2")
+	data[i] = 'E'
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = OpenMappedLogger(filename, 5, InfoLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	m.WriteRecord(&Record{Level: WarningLevel, Message: "after restart", RequestID: "r1",
+		Causes: []ErrorCause{{Type: "*errors.errorString", Message: "after restart"}}})
+	restored := NewMemoryLoggers(10)
+	if err := RestoreMappedLog(filename, restored...); err != nil {
+		t.Fatal(err)
+	}
+	all := restored[0].Merge(restored[1:]...)
+	var messages []string
+	for _, e := range all {
+		messages = append(messages, strings.TrimSpace(e.Message))
+	}
+	if len(all) != 4 || messages[0] != "error 3" || messages[3] != "after restart" {
+		t.Fatalf("Expected corrupted and overwritten entries to be skipped: %q", messages)
+	}
+	if e := all[3]; e.RequestID != "r1" || len(e.Causes) != 1 || e.Causes[0].Type != "*errors.errorString" {
+		t.Fatalf("Expected details to be restored: %+v", e)
+	}
+
+	if _, err := RecoverMappedLog(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("Expected error for missing file")
+	}
+}
@@ -2,44 +2,164 @@ package logging
 
 import (
 	"log"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // MemoryLoggerFlags is the assumed layout for the log
 const MemoryLoggerFlags = log.Lshortfile
 
-// LogEntry is a linked list entry that holds log entries
+// LogEntry is a single entry in a memory log
 type LogEntry struct {
-	Time     time.Time
-	Location string
-	Message  string
-	Next     *LogEntry
-	Level    uint
+	Time      time.Time
+	Location  string
+	Message   string
+	Seq       uint64 // The process-wide sequence number of the entry
+	Level     uint
+	File      string // The full path of the source file (if known)
+	Line      int
+	Function  string       // The function that logged the entry (if known)
+	Goroutine uint64       // The ID of the goroutine that logged the entry (if known)
+	Causes    []ErrorCause // The error chain when an error value is logged
+	Stack     []StackFrame // The stack trace when an error value is logged
+	RequestID string       // The request ID when a context-aware function is used
+	Component string       // The component name when a Component is used
 }
 
-// NewLogEntry creates a new log entry
+// detailMarkers are the prefixes for the detail lines that can follow the
+// log message in text written by other writers.
+var detailMarkers = []string{causeMarker, frameMarker, requestMarker, componentMarker}
+
+// lastSeq is the last sequence number assigned to an entry. The sequence
+// numbers are shared by all memory logs so entries from different logs can be
+// ordered.
+var lastSeq uint64
+
+// NewLogEntry creates a new log entry from the formatted text. The location
+// is parsed from the text, assuming the MemoryLoggerFlags layout. This is
+// only used for text written by other writers than the logging functions;
+// these send a Record with the caller metadata.
 func NewLogEntry(input string, level uint) *LogEntry {
-	fields := strings.Split(input, ":")
-	if len(fields) > 2 {
-		file := fields[0]
-		line := fields[1]
-		message := strings.Join(fields[2:], ":")
-		return &LogEntry{Time: time.Now(), Message: message, Location: file + ":" + line, Next: nil, Level: level}
+	input, details := splitDetails(input)
+	entry := &LogEntry{Time: time.Now(), Message: input, Location: "-", Level: level}
+	if end := locationEnd(input); end > 0 {
+		entry.Location = input[:end]
+		entry.Message = input[end+1:]
+		if i := strings.LastIndexByte(entry.Location, ':'); i > 0 {
+			entry.File = entry.Location[:i]
+			entry.Line, _ = strconv.Atoi(entry.Location[i+1:])
+			entry.Location = shortFile(entry.File) + entry.Location[i:]
+		}
+	}
+	entry.setDetails(details)
+	return entry
+}
+
+// newRecordEntry creates a log entry from a record. The message is used as
+// it is; detail lines are only parsed from text.
+func newRecordEntry(r *Record) LogEntry {
+	entry := LogEntry{
+		Time:      r.Time,
+		Location:  "-",
+		Message:   r.Message,
+		Level:     r.Level,
+		File:      r.File,
+		Line:      r.Line,
+		Function:  r.Function,
+		Goroutine: r.Goroutine,
+		Causes:    r.Causes,
+		Stack:     r.Stack,
+		RequestID: r.RequestID,
+		Component: r.Component,
+	}
+	if r.File != "" {
+		entry.Location = shortFile(r.File) + ":" + strconv.Itoa(r.Line)
+	}
+	return entry
+}
+
+// splitDetails splits the detail lines from the message
+func splitDetails(input string) (string, []string) {
+	start := -1
+	for _, m := range detailMarkers {
+		if i := strings.Index(input, "\n"+m); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+	if start < 0 {
+		return input, nil
+	}
+	return input[:start], strings.Split(strings.TrimRight(input[start+1:], "\n"), "\n")
+}
+
+// locationEnd returns the index of the colon that ends the file:line
+// location at the start of the text or -1 if there's no location. The file
+// name may contain colons, like Windows paths do.
+func locationEnd(input string) int {
+	for i := strings.IndexByte(input, ':'); i > 0; {
+		digits := i + 1
+		for digits < len(input) && input[digits] >= '0' && input[digits] <= '9' {
+			digits++
+		}
+		if digits > i+1 && digits < len(input) && input[digits] == ':' {
+			return digits
+		}
+		next := strings.IndexByte(input[i+1:], ':')
+		if next < 0 {
+			break
+		}
+		i += next + 1
 	}
-	return &LogEntry{Time: time.Now(), Message: input, Location: "-", Next: nil, Level: level}
+	return -1
 }
 
-// MemoryLogger is a type that logs to memory. The logs are stored in a linked
-// list.
+// setDetails sets the fields from the detail lines
+func (e *LogEntry) setDetails(details []string) {
+	if len(details) == 0 {
+		return
+	}
+	e.Causes, e.Stack = parseErrorDetails(e.Message, details)
+	for _, l := range details {
+		switch {
+		case strings.HasPrefix(l, requestMarker):
+			e.RequestID = strings.TrimPrefix(l, requestMarker)
+		case strings.HasPrefix(l, componentMarker):
+			e.Component = strings.TrimPrefix(l, componentMarker)
+		}
+	}
+}
+
+// MemoryLogger is a type that logs to memory. The logs are stored in a
+// preallocated ring buffer. Records from the logging functions are stored
+// as they are and text from other writers is copied into buffers that are
+// reused when the ring wraps around, so writing doesn't allocate once the
+// buffer is warm. The entries are parsed when they are read.
 type MemoryLogger struct {
-	FirstEntry *LogEntry
-	LastEntry  *LogEntry
-	numEntries int
-	maxEntries int
-	level      uint
-	mutex      sync.Mutex
+	slots       []memorySlot
+	count       uint64 // The number of entries written
+	first       uint64 // The position of the oldest entry that is retained
+	maxEntries  int
+	level       uint
+	mutex       sync.Mutex
+	subscribers map[*Subscription]struct{}
+	retention   retention
+	discard     bool // Set if no entries are kept, ie a quota of 0
+}
+
+// memorySlot is a single slot in the ring buffer. The text is used when the
+// slot doesn't hold a record.
+type memorySlot struct {
+	pos    uint64 // The position in the log; 1 is the first entry written
+	seq    uint64 // The process-wide sequence number
+	record Record
+	text   []byte
+	isText bool
+	size   int64 // The size of the message, used for the retention limits
 }
 
 // NewMemoryLogger creates a new memory logger
@@ -48,12 +168,11 @@ func NewMemoryLogger(maxEntries int, l uint) *MemoryLogger {
 		maxEntries = 1
 	}
 	return &MemoryLogger{
-		mutex:      sync.Mutex{},
+		slots:      make([]memorySlot, maxEntries),
+		first:      1,
 		maxEntries: maxEntries,
-		FirstEntry: nil,
-		LastEntry:  nil,
 		level:      l,
-		numEntries: 0}
+	}
 }
 
 // NewMemoryLoggers is a convenience function to create logs for all levels
@@ -66,92 +185,172 @@ func NewMemoryLoggers(maxEntries int) []*MemoryLogger {
 	}
 }
 
-func (m *MemoryLogger) addEntry(entry *LogEntry) {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-	m.numEntries++
-	e := m.FirstEntry
-	if e == nil {
-		m.FirstEntry = entry
-		m.LastEntry = entry
-		return
+// next returns the next slot in the ring buffer, overwriting the oldest entry
+// if the buffer is full. The subscribers are signalled. The mutex must be
+// held.
+func (m *MemoryLogger) next() *memorySlot {
+	for s := range m.subscribers {
+		s.signal()
 	}
-	m.LastEntry.Next = entry
-	m.LastEntry = m.LastEntry.Next
-
-	// Remove the first entry if we exceed max number of entries
-	if m.numEntries > m.maxEntries {
-		remove := m.FirstEntry
-		m.FirstEntry = m.FirstEntry.Next
-		remove.Next = nil
-		return
+	m.count++
+	slot := &m.slots[(m.count-1)%uint64(len(m.slots))]
+	if slot.pos != 0 && slot.pos >= m.first {
+		m.evict(slot, &m.retention.stats.EvictedByCount)
 	}
+	slot.pos = m.count
+	slot.seq = atomic.AddUint64(&lastSeq, 1)
+	return slot
 }
 
-// Write is a stub. This is the io.Writer implementation
+// Write is the io.Writer implementation. The text is parsed when it is read.
 func (m *MemoryLogger) Write(p []byte) (n int, err error) {
-	m.addEntry(NewLogEntry(string(p), m.level))
+	if m.discard {
+		return len(p), nil
+	}
+	now := time.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.reject(int64(len(p))) {
+		return len(p), nil
+	}
+	slot := m.next()
+	slot.record = Record{Time: now, Level: m.level}
+	if cap(slot.text) > maxReusedText && len(p) <= maxReusedText {
+		// Don't keep large buffers around
+		slot.text = nil
+	}
+	slot.text = append(slot.text[:0], p...)
+	slot.isText = true
+	m.retain(slot, int64(len(p)), now)
 	return len(p), nil
 }
 
-// Entries returns the entries
-func (m *MemoryLogger) Entries() []LogEntry {
+// WriteRecord is the RecordWriter implementation
+func (m *MemoryLogger) WriteRecord(r *Record) error {
+	if m.discard {
+		return nil
+	}
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	var ret []LogEntry
-	e := m.FirstEntry
-	for e != nil {
-		ret = append(ret, *e)
-		e = e.Next
+	if m.reject(int64(len(r.Message))) {
+		return nil
 	}
-	return ret
+	slot := m.next()
+	slot.record = *r
+	slot.isText = false
+	m.retain(slot, int64(len(r.Message)), r.Time)
+	return nil
 }
 
-// Merge merges this and a number of other logs
-func (m *MemoryLogger) Merge(other ...*MemoryLogger) []LogEntry {
-	ret := make([]LogEntry, 0)
-	elems := make([]*LogEntry, len(other)+1)
-	elems[0] = m.FirstEntry
+// snapshot copies the entries at the positions from first to last
+// (inclusive), oldest first. Entries that have been overwritten are skipped.
+// Only the copy is made while holding the lock; the entries are parsed
+// later. The text is copied into the record's message for text entries.
+func (m *MemoryLogger) snapshot(first, last uint64) []memorySlot {
 	m.mutex.Lock()
-	for i, o := range other {
-		o.mutex.Lock()
-		elems[i+1] = o.FirstEntry
-	}
-
-	for len(elems) > 0 {
-		// Remove all elems that are nil
-		newElems := make([]*LogEntry, 0)
-		for _, v := range elems {
-			if v != nil {
-				newElems = append(newElems, v)
-			}
-		}
-		elems = newElems
-		// Find the next lowest element and move forward
-		min := time.Now()
-		smallest := -1
-		for i := range elems {
-			if elems[i].Time.Before(min) {
-				min = elems[i].Time
-				smallest = i
-			}
-		}
-		if smallest >= 0 {
-			ret = append(ret, *elems[smallest])
-			elems[smallest] = elems[smallest].Next
+	defer m.mutex.Unlock()
+	if oldest := m.oldest(); first < oldest {
+		first = oldest
+	}
+	if last > m.count {
+		last = m.count
+	}
+	if first > last {
+		return nil
+	}
+	ret := make([]memorySlot, 0, last-first+1)
+	for pos := first; pos <= last; pos++ {
+		slot := *m.slot(pos)
+		if slot.isText {
+			slot.record.Message = string(slot.text)
 		}
+		slot.text = nil
+		ret = append(ret, slot)
 	}
-	m.mutex.Unlock()
-	for _, o := range other {
-		o.mutex.Unlock()
+	return ret
+}
+
+// slot returns the slot for the position. The mutex must be held.
+func (m *MemoryLogger) slot(pos uint64) *memorySlot {
+	return &m.slots[(pos-1)%uint64(len(m.slots))]
+}
+
+// oldest returns the position of the oldest entry in the buffer. Entries
+// older than the retention limits are evicted first. The mutex must be held.
+func (m *MemoryLogger) oldest() uint64 {
+	m.expire(time.Now())
+	return m.first
+}
+
+// bounds returns the positions of the oldest and the last entry. The oldest
+// is higher than the last if the log is empty.
+func (m *MemoryLogger) bounds() (uint64, uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.oldest(), m.count
+}
+
+// positionAfter returns the position of the first entry with a sequence
+// number higher than seq. The sequence numbers are increasing within a log
+// so this is a binary search.
+func (m *MemoryLogger) positionAfter(seq uint64) uint64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	oldest := m.oldest()
+	return oldest + uint64(sort.Search(int(m.count+1-oldest), func(i int) bool {
+		return m.slot(oldest+uint64(i)).seq > seq
+	}))
+}
+
+// parse turns the slots into log entries
+func (m *MemoryLogger) parse(slots []memorySlot) []LogEntry {
+	ret := make([]LogEntry, len(slots))
+	for i := range slots {
+		ret[i] = slots[i].entry()
 	}
 	return ret
 }
 
+// entry turns a slot into a log entry. The record's level is the level of
+// the memory log for text entries.
+func (s *memorySlot) entry() LogEntry {
+	var ret LogEntry
+	if s.isText {
+		ret = *NewLogEntry(s.record.Message, s.record.Level)
+		ret.Time = s.record.Time
+	} else {
+		ret = newRecordEntry(&s.record)
+	}
+	ret.Seq = s.seq
+	return ret
+}
+
+// Entries returns the entries, oldest first
+func (m *MemoryLogger) Entries() []LogEntry {
+	return m.parse(m.snapshot(1, math.MaxUint64))
+}
+
+// EntriesSince returns the entries with a sequence number higher than seq,
+// oldest first. Use the sequence number of the last entry returned to get
+// new entries only.
+func (m *MemoryLogger) EntriesSince(seq uint64) []LogEntry {
+	return m.parse(m.snapshot(m.positionAfter(seq), math.MaxUint64))
+}
+
+// Merge merges this and a number of other logs. The entries are ordered by
+// their sequence number.
+func (m *MemoryLogger) Merge(other ...*MemoryLogger) []LogEntry {
+	lists := [][]LogEntry{m.Entries()}
+	for _, o := range other {
+		lists = append(lists, o.Entries())
+	}
+	return mergeEntries(lists, false)
+}
+
 // NumEntries returns the number of entries in the log in total. This
 // is increased for every time something is logged to the logger.
 func (m *MemoryLogger) NumEntries() int {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	return m.numEntries
+	return int(m.count)
 }
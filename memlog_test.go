@@ -1,6 +1,8 @@
 package logging
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -43,9 +45,99 @@ func TestMemloggerMerge(t *testing.T) {
 		prev = v.Time
 	}
 }
+func TestMemloggerRing(t *testing.T) {
+	ml := NewMemoryLogger(5, InfoLevel)
+	for i := 1; i <= 12; i++ {
+		fmt.Fprintf(ml, "main.go:%d: entry %d\n", i, i)
+	}
+	entries := ml.Entries()
+	if len(entries) != 5 || ml.NumEntries() != 12 {
+		t.Fatalf("Expected 5 of 12 entries but got %d of %d", len(entries), ml.NumEntries())
+	}
+	first := entries[0].Seq
+	for i, e := range entries {
+		n := i + 8
+		if e.Seq != first+uint64(i) || e.Message != fmt.Sprintf(" entry %d\n", n) || e.Location != fmt.Sprintf("main.go:%d", n) {
+			t.Fatalf("Unexpected entry %d: %+v", i, e)
+		}
+	}
+	if since := ml.EntriesSince(first + 2); len(since) != 2 || since[0].Seq != first+3 {
+		t.Fatalf("Expected the last two entries but got %+v", since)
+	}
+	if since := ml.EntriesSince(first - 5); len(since) != 5 {
+		t.Fatalf("Expected all entries but got %d", len(since))
+	}
+	if since := ml.EntriesSince(first + 4); len(since) != 0 {
+		t.Fatalf("Expected no entries but got %d", len(since))
+	}
+}
+
+func TestMemloggerWriteAllocs(t *testing.T) {
+	ml := NewMemoryLogger(10, DebugLevel)
+	line := []byte("main.go:57: This is a log entry:with:colon")
+	for i := 0; i < 10; i++ {
+		ml.Write(line)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { ml.Write(line) }); allocs != 0 {
+		t.Fatalf("Expected no allocations when writing but got %v", allocs)
+	}
+}
+
 func BenchmarkMemlogger(b *testing.B) {
 	ml := NewMemoryLogger(1000, WarningLevel)
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		ml.Write([]byte("main.go:57: This is a log entry:with:colon"))
 	}
 }
+
+func BenchmarkMemloggerParallel(b *testing.B) {
+	ml := NewMemoryLogger(1000, WarningLevel)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		line := []byte("main.go:57: This is a log entry:with:colon")
+		for pb.Next() {
+			ml.Write(line)
+		}
+	})
+}
+
+func BenchmarkMemloggerEntries(b *testing.B) {
+	ml := NewMemoryLogger(1000, WarningLevel)
+	for i := 0; i < 1000; i++ {
+		ml.Write([]byte("main.go:57: This is a log entry:with:colon"))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ml.Entries()
+	}
+}
+
+// Write while another goroutine reads the entries continuously
+func BenchmarkMemloggerWriteWhileReading(b *testing.B) {
+	ml := NewMemoryLogger(1000, WarningLevel)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				ml.Entries()
+			}
+		}
+	}()
+	line := []byte("main.go:57: This is a log entry:with:colon")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ml.Write(line)
+	}
+	b.StopTimer()
+	close(done)
+	wg.Wait()
+}
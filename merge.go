@@ -0,0 +1,77 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import "container/heap"
+
+// entryBefore orders entries by sequence number and then by time. Entries
+// from different logs always have different sequence numbers but entries
+// that haven't been assigned one (with Seq set to 0) are ordered by time.
+func entryBefore(a, b *LogEntry) bool {
+	if a.Seq != b.Seq {
+		return a.Seq < b.Seq
+	}
+	return a.Time.Before(b.Time)
+}
+
+// mergeHeap is a heap with the first remaining entry of each list
+type mergeHeap struct {
+	lists   [][]LogEntry
+	reverse bool
+}
+
+func (h *mergeHeap) Len() int { return len(h.lists) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	if h.reverse {
+		return entryBefore(&h.lists[j][0], &h.lists[i][0])
+	}
+	return entryBefore(&h.lists[i][0], &h.lists[j][0])
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.lists[i], h.lists[j] = h.lists[j], h.lists[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.lists = append(h.lists, x.([]LogEntry)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	last := h.lists[len(h.lists)-1]
+	h.lists = h.lists[:len(h.lists)-1]
+	return last
+}
+
+// mergeEntries merges lists of entries that are already ordered. The lists
+// are ordered newest first if reverse is set.
+func mergeEntries(lists [][]LogEntry, reverse bool) []LogEntry {
+	h := &mergeHeap{reverse: reverse}
+	total := 0
+	for _, l := range lists {
+		if len(l) > 0 {
+			h.lists = append(h.lists, l)
+			total += len(l)
+		}
+	}
+	heap.Init(h)
+	ret := make([]LogEntry, 0, total)
+	for h.Len() > 0 {
+		ret = append(ret, h.lists[0][0])
+		if h.lists[0] = h.lists[0][1:]; len(h.lists[0]) == 0 {
+			heap.Pop(h)
+			continue
+		}
+		heap.Fix(h, 0)
+	}
+	return ret
+}
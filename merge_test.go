@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMergeOrder(t *testing.T) {
+	logs := NewMemoryLoggers(50)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(logs[i%3], "main.go:1: %d\n", i)
+	}
+	entries := logs[0].Merge(logs[1:]...)
+	if len(entries) != 100 {
+		t.Fatalf("Expected 100 entries but got %d", len(entries))
+	}
+	for i, e := range entries {
+		if e.Message != fmt.Sprintf(" %d\n", i) {
+			t.Fatalf("Expected entry %d but got %q", i, e.Message)
+		}
+	}
+}
+
+func TestMergeEntries(t *testing.T) {
+	now := time.Now()
+	a := []LogEntry{{Seq: 1}, {Seq: 4}, {Seq: 5}}
+	b := []LogEntry{{Seq: 2}, {Seq: 3}, {Seq: 6}}
+	c := []LogEntry{{Time: now}, {Time: now.Add(time.Second)}}
+	merged := mergeEntries([][]LogEntry{a, b, nil, c[1:], c[:1]}, false)
+	expected := []uint64{0, 0, 1, 2, 3, 4, 5, 6}
+	if len(merged) != len(expected) {
+		t.Fatalf("Expected %d entries but got %d", len(expected), len(merged))
+	}
+	for i, e := range merged {
+		if e.Seq != expected[i] {
+			t.Fatalf("Expected seq %d at %d but got %d", expected[i], i, e.Seq)
+		}
+	}
+	if !merged[0].Time.Equal(now) {
+		t.Fatal("Entries without sequence numbers should be ordered by time")
+	}
+	reversed := mergeEntries([][]LogEntry{{{Seq: 5}, {Seq: 4}, {Seq: 1}}, {{Seq: 6}, {Seq: 3}, {Seq: 2}}}, true)
+	for i, e := range reversed {
+		if e.Seq != uint64(6-i) {
+			t.Fatalf("Expected seq %d at %d but got %d", 6-i, i, e.Seq)
+		}
+	}
+}
+
+func TestMergeConcurrentWriters(t *testing.T) {
+	logs := NewMemoryLoggers(1000)
+	var wg sync.WaitGroup
+	for _, l := range logs {
+		wg.Add(1)
+		go func(l *MemoryLogger) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				fmt.Fprintf(l, "main.go:1: entry\n")
+			}
+		}(l)
+	}
+	wg.Wait()
+	entries := logs[0].Merge(logs[1:]...)
+	if len(entries) != 800 {
+		t.Fatalf("Expected 800 entries but got %d", len(entries))
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Seq <= entries[i-1].Seq {
+			t.Fatalf("Entries are not ordered: %d after %d", entries[i].Seq, entries[i-1].Seq)
+		}
+	}
+}
+
+func BenchmarkMerge(b *testing.B) {
+	logs := NewMemoryLoggers(1000)
+	for i := 0; i < 4000; i++ {
+		logs[i%4].Write([]byte("main.go:57: This is a log entry"))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logs[0].Merge(logs[1:]...)
+	}
+}
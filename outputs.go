@@ -0,0 +1,153 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"io"
+	"log"
+	"log/syslog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// outputs is an immutable snapshot of the log outputs. The outputs are never
+// modified once they are published; a new snapshot is created and swapped in
+// every time the outputs change. This ensures a log line is always written
+// with the output, prefix and flags that belong together.
+type outputs struct {
+	// The loggers for each level:
+	//
+	// Debug messages aren't really useful for anything except the developers.
+	//
+	// Info messages are typically "application created", "user registered",
+	// "device deleted" and so on. They are useful when doing detailed
+	// monitoring of the service.
+	//
+	// Warnings are typically inconsistencies that users might notice. There
+	// are *some* messages in this but not a lot.
+	//
+	// Errors are severe; database errors, data inconsistencies, failures and
+	// issues that require immediate action. There are very few issues on this
+	// scale.
+	levels [ErrorLevel + 1]*log.Logger
+	// std is the logger for messages from the standard log package
+	std *log.Logger
+	// syslog is the syslog writers used by the loggers (if any). They are
+	// closed when the snapshot is replaced and no longer in use.
+	syslog []*syslog.Writer
+	// active is the number of goroutines currently writing to the outputs
+	active int64
+}
+
+// newOutputs creates a snapshot where all levels use the same writer and
+// flags. The prefixes are for the debug, info, warning and error levels
+// followed by the prefix for the standard log package.
+func newOutputs(w io.Writer, flags int, prefixes ...string) *outputs {
+	ret := &outputs{}
+	prefix := func(i int) string {
+		if i < len(prefixes) {
+			return prefixes[i]
+		}
+		return ""
+	}
+	for i := range ret.levels {
+		ret.levels[i] = log.New(w, prefix(i), flags)
+	}
+	ret.std = log.New(w, prefix(len(ret.levels)), flags)
+	return ret
+}
+
+// settings is an immutable snapshot of the outputs and the settings that
+// decide what is logged. Like the outputs the settings are never modified;
+// a copy with the changes is swapped in. A log call uses one snapshot
+// throughout so it never sees new outputs with the old log level or
+// sampling, or the other way around.
+type settings struct {
+	outputs    *outputs
+	level      uint
+	components map[string]uint       // The log levels for the components
+	samplers   *[ErrorLevel]*sampler // Nil samplers are not sampled
+	theme      Theme                 // The theme for the stderr output
+}
+
+// currentSettings holds the active *settings
+var currentSettings atomic.Value
+
+// settingsMutex serializes the changes to the settings
+var settingsMutex sync.Mutex
+
+// acquireSettings returns the current settings. Call release when done
+// writing. The outputs are rechecked after they are marked as active so the
+// syslog writers won't be closed while they are in use.
+func acquireSettings() *settings {
+	for {
+		s := currentSettings.Load().(*settings)
+		atomic.AddInt64(&s.outputs.active, 1)
+		if currentSettings.Load().(*settings).outputs == s.outputs {
+			return s
+		}
+		s.outputs.release()
+	}
+}
+
+func (s *settings) release() {
+	s.outputs.release()
+}
+
+func (o *outputs) release() {
+	atomic.AddInt64(&o.active, -1)
+}
+
+// logLevel returns the log level for the component. The global log level is
+// used if the component has no level of its own or the name is empty.
+func (s *settings) logLevel(component string) uint {
+	if l, ok := s.components[component]; ok && component != "" {
+		return l
+	}
+	return s.level
+}
+
+// updateSettings applies the changes to a copy of the current settings and
+// makes the copy the current settings. If the outputs are replaced the
+// syslog writers in the previous outputs are closed when they are no longer
+// in use.
+func updateSettings(change func(s *settings)) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	old := currentSettings.Load().(*settings)
+	s := *old
+	change(&s)
+	currentSettings.Store(&s)
+	if s.outputs == old.outputs || len(old.outputs.syslog) == 0 {
+		return
+	}
+	go func(o *outputs) {
+		for atomic.LoadInt64(&o.active) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		for _, w := range o.syslog {
+			w.Close()
+		}
+	}(old.outputs)
+}
+
+// publish makes the outputs the current outputs
+func publish(o *outputs) {
+	updateSettings(func(s *settings) {
+		s.outputs = o
+	})
+}
@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Log from several goroutines while the outputs are swapped. Run this with
+// -race to check that the outputs are published safely.
+func TestSwapOutputsWhileLogging(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	stderr := os.Stderr
+	os.Stderr = devNull
+	defer func() {
+		os.Stderr = stderr
+		EnableStderr(true)
+		ReleaseStdLog()
+		SetLogLevel(WarningLevel)
+	}()
+
+	memoryLogs := [][]*MemoryLogger{NewMemoryLoggers(1000), NewMemoryLoggers(1000)}
+	SetLogLevel(DebugLevel)
+
+	done := make(chan struct{})
+	var loggers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		loggers.Add(1)
+		go func() {
+			defer loggers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				Debug("debug message")
+				Info("info message")
+				Warning("warning message")
+				Error("error message")
+				log.Printf("std message")
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		switch i % 4 {
+		case 0:
+			EnableMemoryLogger(memoryLogs[0])
+		case 1:
+			EnableStderr(i%8 == 1)
+			CaptureStdLog(InfoLevel)
+		case 2:
+			if err := Configure(Config{Output: MemoryOutput, MemoryEntries: 10}); err != nil {
+				t.Fatal(err)
+			}
+			ReleaseStdLog()
+		case 3:
+			if err := SetMemoryOutput(memoryLogs[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		Flush()
+	}
+	close(done)
+	loggers.Wait()
+
+	for _, logs := range memoryLogs {
+		for _, m := range logs {
+			for _, e := range m.Entries() {
+				if !strings.HasPrefix(e.Location, "outputs_test.go:") {
+					t.Fatalf("Entry has wrong location: %+v", e)
+				}
+				if !strings.HasSuffix(e.Message, " message") || strings.Contains(e.Message, "\x1b") {
+					t.Fatalf("Entry has mismatched prefix: %q", e.Message)
+				}
+			}
+		}
+	}
+}
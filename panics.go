@@ -0,0 +1,107 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// repanic is set to 1 when recovered panics should be re-raised after logging
+var repanic uint32 = 1
+
+// SetRepanic controls what happens after RecoverAndLog has logged a panic. If
+// enabled (the default) the panic is raised again and the process crashes as
+// it normally would. If disabled the goroutine continues after the deferred
+// call.
+func SetRepanic(enabled bool) {
+	var v uint32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreUint32(&repanic, v)
+}
+
+// RecoverAndLog recovers a panic, logs it at error level together with the
+// goroutine's stack and flushes the log outputs. The automatic snapshot is
+// saved if it is enabled (see EnableAutoSnapshot). It must be called
+// directly via defer:
+//
+//	defer logging.RecoverAndLog()
+//
+// The panic is raised again after logging unless SetRepanic(false) has been
+// called.
+func RecoverAndLog() {
+	if r := recover(); r != nil {
+		logPanic(r)
+		Flush()
+		saveAutoSnapshot()
+		if atomic.LoadUint32(&repanic) == 1 {
+			panic(r)
+		}
+	}
+}
+
+// Go launches the function in a new goroutine. Panics in the goroutine are
+// handled by RecoverAndLog.
+func Go(f func()) {
+	go func() {
+		defer RecoverAndLog()
+		f()
+	}()
+}
+
+// logPanic logs the recovered value. The location and stack trace starts at
+// the function that panicked rather than in the deferred function.
+func logPanic(r interface{}) {
+	var causes []ErrorCause
+	if err, ok := r.(error); ok {
+		causes = errorChain(err)
+	} else {
+		causes = []ErrorCause{{Type: fmt.Sprintf("%T", r), Message: fmt.Sprint(r)}}
+	}
+
+	// The stack is logPanic, RecoverAndLog, runtime.gopanic (and possibly
+	// more runtime functions) and then the function that panicked.
+	stack := framesFromPCs(goroutineStack())
+	depth := 1
+	for i, f := range stack {
+		if f.Function == "runtime.gopanic" {
+			depth = i + 1
+			for depth < len(stack) && strings.HasPrefix(stack[depth].Function, "runtime.") {
+				depth++
+			}
+			stack = stack[depth:]
+			break
+		}
+	}
+	outputTo(nil, depth+1, Record{Level: ErrorLevel, Message: fmt.Sprintf("panic: %v", r), Causes: causes, Stack: stack})
+}
+
+// goroutineStack returns the program counters for the entire stack of the
+// calling goroutine, starting with the caller of goroutineStack.
+func goroutineStack() []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	for {
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			return pcs[:n]
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func panicky() {
+	panic("boom")
+}
+
+func TestGoRecovers(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetRepanic(false)
+	defer SetRepanic(true)
+
+	Go(panicky)
+
+	start := time.Now()
+	for logs[ErrorLevel].NumEntries() == 0 {
+		if time.Since(start) > time.Second {
+			t.Fatal("Panic was not logged")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	e := logs[ErrorLevel].Entries()[0]
+	if !strings.Contains(e.Message, "panic: boom") {
+		t.Fatalf("Incorrect message: %q", e.Message)
+	}
+	if !strings.HasPrefix(e.Location, "panics_test.go:") {
+		t.Fatalf("Location should be where the panic occurred but was %s", e.Location)
+	}
+	if len(e.Causes) != 1 || e.Causes[0].Type != "string" {
+		t.Fatalf("Incorrect causes: %v", e.Causes)
+	}
+	if len(e.Stack) == 0 || !strings.HasSuffix(e.Stack[0].Function, "panicky") {
+		t.Fatalf("Stack should start at the panic: %v", e.Stack)
+	}
+}
+
+func TestRecoverAndLogRepanics(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatalf("Expected the panic to be raised again but got %v", r)
+		}
+		if logs[ErrorLevel].NumEntries() != 1 {
+			t.Fatal("Expected the panic to be logged")
+		}
+	}()
+	func() {
+		defer RecoverAndLog()
+		panicky()
+	}()
+}
@@ -0,0 +1,240 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scanChunk is the number of entries copied from the ring buffer at a time
+// when scanning. The lock is released between each chunk.
+const scanChunk = 64
+
+// Query is a filter for memory log entries. The zero value matches all
+// entries. All of the conditions that are set must match.
+type Query struct {
+	// Levels is the set of levels to include. All levels are included if
+	// this is empty.
+	Levels []uint
+	// Since and Until limits the entries to a time range. Since is inclusive
+	// and Until is exclusive. Zero values are ignored.
+	Since time.Time
+	Until time.Time
+	// Location is a prefix for the location (like "store.go" or
+	// "store.go:12") or the full path of the source file.
+	Location string
+	// Component is the name of the component that logged the entry.
+	Component string
+	// Fields are name=value pairs that must be in the message, like the
+	// ones written by the access log. Quoted values are unquoted before they
+	// are compared. The "request" field matches the request ID.
+	Fields map[string]string
+	// After and Before limits the entries to a range of sequence numbers
+	// (exclusive). Zero values are ignored. Use these for paging.
+	After  uint64
+	Before uint64
+	// Text is a substring of the message.
+	Text string
+	// Expr is a regular expression for the message.
+	Expr *regexp.Regexp
+	// Limit is the maximum number of entries returned. 0 is no limit.
+	Limit int
+	// Reverse returns the newest entries first.
+	Reverse bool
+}
+
+// Query returns the entries that match the query
+func (m *MemoryLogger) Query(q Query) []LogEntry {
+	var ret []LogEntry
+	m.Scan(q, func(e LogEntry) bool {
+		ret = append(ret, e)
+		return true
+	})
+	return ret
+}
+
+// Scan calls fn for each entry that matches the query until fn returns false
+// or the limit is reached. The entries are copied from the ring buffer in
+// small chunks so the writers aren't blocked while scanning. Entries written
+// after the scan started are not included.
+func (m *MemoryLogger) Scan(q Query, fn func(e LogEntry) bool) {
+	first, last := m.bounds()
+	if q.After > 0 {
+		if pos := m.positionAfter(q.After); pos > first {
+			first = pos
+		}
+	}
+	count := 0
+	visit := func(slot *memorySlot) bool {
+		if !q.matchSlot(slot, m.level) {
+			return true
+		}
+		e := slot.entry()
+		if !q.Match(e) {
+			return true
+		}
+		count++
+		return fn(e) && (q.Limit <= 0 || count < q.Limit)
+	}
+	if q.Reverse {
+		for end := last; end >= first && end > 0; {
+			start := first
+			if end-first >= scanChunk {
+				start = end - scanChunk + 1
+			}
+			chunk := m.snapshot(start, end)
+			for i := len(chunk) - 1; i >= 0; i-- {
+				if !visit(&chunk[i]) {
+					return
+				}
+			}
+			if len(chunk) == 0 || chunk[0].pos != start {
+				// The rest has been overwritten
+				return
+			}
+			end = start - 1
+		}
+		return
+	}
+	for next := first; next <= last; {
+		end := next + scanChunk - 1
+		if end > last {
+			end = last
+		}
+		chunk := m.snapshot(next, end)
+		if len(chunk) == 0 {
+			return
+		}
+		for i := range chunk {
+			if !visit(&chunk[i]) {
+				return
+			}
+		}
+		next = chunk[len(chunk)-1].pos + 1
+	}
+}
+
+// QueryLogs returns the entries in a set of memory logs that match the
+// query, ordered by sequence number. The limit applies to the total number of
+// entries.
+func QueryLogs(q Query, logs ...*MemoryLogger) []LogEntry {
+	var lists [][]LogEntry
+	for _, m := range logs {
+		lists = append(lists, m.Query(q))
+	}
+	ret := mergeEntries(lists, q.Reverse)
+	if q.Limit > 0 && len(ret) > q.Limit {
+		ret = ret[:q.Limit]
+	}
+	return ret
+}
+
+// matchSlot checks the level and time before the slot is parsed
+func (q *Query) matchSlot(slot *memorySlot, level uint) bool {
+	if !slot.isText {
+		level = slot.record.Level
+	}
+	return q.matchLevel(level) && q.matchTime(slot.record.Time) && q.matchSeq(slot.seq)
+}
+
+func (q *Query) matchLevel(level uint) bool {
+	if len(q.Levels) == 0 {
+		return true
+	}
+	for _, l := range q.Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Query) matchSeq(seq uint64) bool {
+	return seq > q.After && (q.Before == 0 || seq < q.Before)
+}
+
+func (q *Query) matchTime(t time.Time) bool {
+	return (q.Since.IsZero() || !t.Before(q.Since)) && (q.Until.IsZero() || t.Before(q.Until))
+}
+
+// Match checks if the entry matches the query
+func (q *Query) Match(e LogEntry) bool {
+	if !q.matchLevel(e.Level) || !q.matchTime(e.Time) || !q.matchSeq(e.Seq) {
+		return false
+	}
+	if q.Location != "" && !strings.HasPrefix(e.Location, q.Location) && !strings.HasPrefix(e.File, q.Location) {
+		return false
+	}
+	if q.Component != "" && e.Component != q.Component {
+		return false
+	}
+	for name, value := range q.Fields {
+		if v, ok := e.Field(name); !ok || v != value {
+			return false
+		}
+	}
+	if q.Text != "" && !strings.Contains(e.Message, q.Text) {
+		return false
+	}
+	return q.Expr == nil || q.Expr.MatchString(e.Message)
+}
+
+// Field returns the value of a name=value field in the message. Quoted
+// values are unquoted. The "request" field is the request ID.
+func (e *LogEntry) Field(name string) (string, bool) {
+	if name == "request" && e.RequestID != "" {
+		return e.RequestID, true
+	}
+	msg := e.Message
+	for {
+		i := strings.Index(msg, name+"=")
+		if i < 0 {
+			return "", false
+		}
+		value := msg[i+len(name)+1:]
+		if i > 0 && msg[i-1] != ' ' && msg[i-1] != '\t' {
+			msg = value
+			continue
+		}
+		if unquoted, ok := unquotePrefix(value); ok {
+			return unquoted, true
+		}
+		if end := strings.IndexAny(value, " \t\n"); end >= 0 {
+			value = value[:end]
+		}
+		return value, true
+	}
+}
+
+// unquotePrefix unquotes the Go string literal at the start of s
+func unquotePrefix(s string) (string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", false
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			unquoted, err := strconv.Unquote(s[:i+1])
+			return unquoted, err == nil
+		}
+	}
+	return "", false
+}
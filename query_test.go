@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	// The first 101 entries are overwritten
+	ml := NewMemoryLogger(200, InfoLevel)
+	start := time.Now()
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(ml, "store.go:%d: entry %d device=%d name=\"a b\"\n", i, i, i%10)
+	}
+	fmt.Fprintf(ml, "api.go:1: other entry\n\tcomponent api\n")
+
+	tests := []struct {
+		name  string
+		query Query
+		count int
+		first string
+	}{
+		{"all", Query{}, 200, " entry 101 device=1 name=\"a b\"\n"},
+		{"location", Query{Location: "store.go"}, 199, " entry 101 device=1 name=\"a b\"\n"},
+		{"line", Query{Location: "store.go:15"}, 10, " entry 150 device=0 name=\"a b\"\n"},
+		{"component", Query{Component: "api"}, 1, " other entry"},
+		{"field", Query{Fields: map[string]string{"device": "3", "name": "a b"}}, 20, " entry 103 device=3 name=\"a b\"\n"},
+		{"text", Query{Text: "entry 29"}, 10, " entry 290 device=0 name=\"a b\"\n"},
+		{"expr", Query{Expr: regexp.MustCompile(`entry 1\d\d `)}, 99, " entry 101 device=1 name=\"a b\"\n"},
+		{"limit", Query{Limit: 5}, 5, " entry 101 device=1 name=\"a b\"\n"},
+		{"reverse", Query{Reverse: true, Limit: 3}, 3, " other entry"},
+		{"reverse location", Query{Reverse: true, Location: "store.go", Limit: 250}, 199, " entry 299 device=9 name=\"a b\"\n"},
+		{"level", Query{Levels: []uint{ErrorLevel}}, 0, ""},
+		{"time", Query{Since: start, Until: time.Now().Add(time.Second)}, 200, " entry 101 device=1 name=\"a b\"\n"},
+		{"future", Query{Since: time.Now().Add(time.Second)}, 0, ""},
+	}
+	for _, test := range tests {
+		entries := ml.Query(test.query)
+		if len(entries) != test.count {
+			t.Errorf("%s: Expected %d entries but got %d", test.name, test.count, len(entries))
+			continue
+		}
+		if len(entries) > 0 && entries[0].Message != test.first {
+			t.Errorf("%s: Expected first entry %q but got %q", test.name, test.first, entries[0].Message)
+		}
+	}
+
+	scanned := 0
+	ml.Scan(Query{}, func(e LogEntry) bool {
+		scanned++
+		return scanned < 10
+	})
+	if scanned != 10 {
+		t.Fatalf("Expected scan to stop after 10 entries but got %d", scanned)
+	}
+}
+
+func TestQueryLogs(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(DebugLevel)
+	defer SetLogLevel(WarningLevel)
+	for i := 0; i < 5; i++ {
+		Debug("debug %d", i)
+		Error("error %d", i)
+	}
+	errors := QueryLogs(Query{Levels: []uint{ErrorLevel}, Location: "query_test.go"}, logs...)
+	if len(errors) != 5 || errors[0].Message != "error 0" {
+		t.Fatalf("Expected 5 errors but got %+v", errors)
+	}
+	latest := QueryLogs(Query{Reverse: true, Limit: 3}, logs...)
+	if len(latest) != 3 || latest[0].Message != "error 4" || latest[2].Message != "error 3" {
+		t.Fatalf("Expected the latest entries but got %+v", latest)
+	}
+}
+
+func TestEntryField(t *testing.T) {
+	e := LogEntry{Message: `GET /x status=200 agent="a \"b\"" xstatus=500`, RequestID: "r1"}
+	for name, expected := range map[string]string{"status": "200", "agent": `a "b"`, "request": "r1"} {
+		if v, ok := e.Field(name); !ok || v != expected {
+			t.Errorf("Expected %s=%s but got %q", name, expected, v)
+		}
+	}
+	if _, ok := e.Field("missing"); ok {
+		t.Error("Did not expect to find missing field")
+	}
+}
@@ -0,0 +1,208 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Record is a log message with the metadata for the call. Outputs that
+// implement RecordWriter get the record directly instead of the formatted
+// text. The causes, stack trace, request ID and component are only rendered
+// as detail lines for the text outputs.
+type Record struct {
+	Time      time.Time // The time of the log call
+	Level     uint
+	File      string // The full path of the source file
+	Line      int
+	Function  string       // The fully qualified function name
+	Goroutine uint64       // The ID of the calling goroutine
+	Message   string       // The message
+	Causes    []ErrorCause // The error chain when an error value is logged
+	Stack     []StackFrame // The stack trace when an error value is logged
+	RequestID string       // The request ID when a context-aware function is used
+	Component string       // The component name when a Component is used
+}
+
+// RecordWriter is implemented by outputs that accept records. MemoryLogger
+// implements this.
+type RecordWriter interface {
+	WriteRecord(r *Record) error
+}
+
+// mirror holds the RecordWriter that gets a copy of all records
+var mirror atomic.Value
+
+// mirrorHolder wraps the RecordWriter since atomic.Value can't store nil or
+// values of different types.
+type mirrorHolder struct {
+	w RecordWriter
+}
+
+// SetMirror sends a copy of every message logged through the global log
+// functions to the RecordWriter, in addition to the outputs. This is kept
+// when the outputs are changed. Use a MappedLogger as the mirror to keep the
+// last entries if the process crashes. Set it to nil to turn off the mirror.
+func SetMirror(w RecordWriter) {
+	mirror.Store(mirrorHolder{w})
+}
+
+func currentMirror() RecordWriter {
+	h, _ := mirror.Load().(mirrorHolder)
+	return h.w
+}
+
+// setCaller sets the time, goroutine and the location of the log call. The
+// calldepth parameter is relative to the caller of setCaller, the same way
+// as for runtime.Caller.
+func (r *Record) setCaller(calldepth int) {
+	r.Time, r.Goroutine = time.Now(), goroutineID()
+	var pcs [1]uintptr
+	if runtime.Callers(calldepth+2, pcs[:]) > 0 {
+		frame, _ := runtime.CallersFrames(pcs[:]).Next()
+		r.File, r.Line, r.Function = frame.File, frame.Line, frame.Function
+	}
+}
+
+// redact redacts the message and the messages in the error chain
+func (r *Record) redact() {
+	r.Message = redact(r.Message)
+	if len(r.Causes) == 0 || currentRedactor.Load().(*redactor) == nil {
+		return
+	}
+	causes := make([]ErrorCause, len(r.Causes))
+	for i, c := range r.Causes {
+		causes[i] = ErrorCause{Type: c.Type, Message: redact(c.Message)}
+	}
+	r.Causes = causes
+}
+
+// text returns the message followed by the detail lines for the text
+// outputs. NewLogEntry parses the detail lines when the text is written to a
+// MemoryLogger by other writers.
+func (r *Record) text() string {
+	if len(r.Causes) == 0 && len(r.Stack) == 0 && r.RequestID == "" && r.Component == "" {
+		return r.Message
+	}
+	var sb strings.Builder
+	sb.WriteString(r.Message)
+	sb.WriteString(formatErrorDetails(r.Causes, r.Stack))
+	if r.RequestID != "" {
+		sb.WriteString("\n" + requestMarker + r.RequestID)
+	}
+	if r.Component != "" {
+		sb.WriteString("\n" + componentMarker + r.Component)
+	}
+	return sb.String()
+}
+
+// appendDetails appends the causes, stack trace, request ID and component to
+// buf. Snapshots and mapped logs use this encoding; it is a sequence of
+// uvarints and length-prefixed strings.
+func (r *Record) appendDetails(buf []byte) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	putUint := func(v uint64) {
+		buf = append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+	}
+	putString := func(s string) {
+		putUint(uint64(len(s)))
+		buf = append(buf, s...)
+	}
+	putString(r.RequestID)
+	putString(r.Component)
+	putUint(uint64(len(r.Causes)))
+	for _, c := range r.Causes {
+		putString(c.Type)
+		putString(c.Message)
+	}
+	putUint(uint64(len(r.Stack)))
+	for _, f := range r.Stack {
+		putString(f.Function)
+		putString(f.File)
+		putUint(uint64(f.Line))
+	}
+	return buf
+}
+
+// readDetails decodes the details encoded by appendDetails. The details may
+// be truncated; the causes and frames that are complete are kept.
+func (r *Record) readDetails(data []byte) {
+	ok := true
+	getUint := func() uint64 {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			ok = false
+			return 0
+		}
+		data = data[n:]
+		return v
+	}
+	getString := func() string {
+		n := getUint()
+		if !ok || n > uint64(len(data)) {
+			ok = false
+			return ""
+		}
+		s := string(data[:n])
+		data = data[n:]
+		return s
+	}
+	r.RequestID = getString()
+	r.Component = getString()
+	for n := getUint(); ok && n > 0; n-- {
+		c := ErrorCause{Type: getString(), Message: getString()}
+		if ok {
+			r.Causes = append(r.Causes, c)
+		}
+	}
+	for n := getUint(); ok && n > 0; n-- {
+		f := StackFrame{Function: getString(), File: getString(), Line: int(getUint())}
+		if ok {
+			r.Stack = append(r.Stack, f)
+		}
+	}
+}
+
+// goroutineID returns the ID of the current goroutine. The runtime doesn't
+// expose this so it is read from the first line of the stack trace, ie
+// "goroutine 18 [running]:".
+func goroutineID() uint64 {
+	var buf [32]byte
+	n := runtime.Stack(buf[:], false)
+	fields := bytes.Fields(buf[:n])
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(fields[1]), 10, 64)
+	return id
+}
+
+// shortFile returns the file name without the directory, the same way as
+// the log.Lshortfile flag.
+func shortFile(file string) string {
+	for i := len(file) - 1; i >= 0; i-- {
+		if file[i] == '/' || file[i] == '\\' {
+			return file[i+1:]
+		}
+	}
+	return file
+}
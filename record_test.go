@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecords(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	before := time.Now()
+	Error("Error with record")
+	entries := logs[ErrorLevel].Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry but got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Message != "Error with record" || e.Level != ErrorLevel {
+		t.Fatalf("Incorrect message or level: %+v", e)
+	}
+	if !strings.HasSuffix(e.File, "/record_test.go") || !strings.HasPrefix(e.Location, "record_test.go:") || e.Line == 0 {
+		t.Fatalf("Incorrect location: %s %s %d", e.Location, e.File, e.Line)
+	}
+	if !strings.HasSuffix(e.Function, ".TestRecords") || e.Goroutine == 0 {
+		t.Fatalf("Incorrect function or goroutine: %s %d", e.Function, e.Goroutine)
+	}
+	if e.Time.Before(before) || e.Time.After(time.Now()) {
+		t.Fatalf("Incorrect time: %v", e.Time)
+	}
+}
+
+func TestRecordDetails(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	Error("input: %s", "x\n\trequest admin-123")
+	Error("trace:\n\tat com.example.Main.main(Main.java:10)")
+	ErrorContext(WithRequestID(context.Background(), "r1"), "with request")
+	Err(fmt.Errorf("outer: %w", errors.New("inner")))
+	entries := logs[ErrorLevel].Entries()
+	if e := entries[0]; e.RequestID != "" || e.Message != "input: x\n\trequest admin-123" {
+		t.Fatalf("Message text should not be parsed: %+v", e)
+	}
+	if e := entries[1]; len(e.Stack) != 0 || !strings.HasSuffix(e.Message, "Main.java:10)") {
+		t.Fatalf("Message text should not be parsed as a stack trace: %+v", e)
+	}
+	if e := entries[2]; e.RequestID != "r1" || e.Message != "with request" {
+		t.Fatalf("Expected request ID in record: %+v", e)
+	}
+	if e := entries[3]; e.Message != "outer: inner" || len(e.Causes) != 2 || e.Causes[1].Message != "inner" || len(e.Stack) == 0 {
+		t.Fatalf("Expected causes and stack in record: %+v", e)
+	}
+
+	r := Record{RequestID: "r1", Component: "store", Causes: entries[3].Causes, Stack: entries[3].Stack}
+	data := r.appendDetails(nil)
+	var decoded Record
+	decoded.readDetails(data)
+	if decoded.RequestID != "r1" || decoded.Component != "store" || len(decoded.Causes) != 2 || len(decoded.Stack) != len(r.Stack) {
+		t.Fatalf("Details differ after decoding: %+v", decoded)
+	}
+	var truncated Record
+	truncated.readDetails(data[:len(data)-1])
+	if len(truncated.Causes) != 2 || len(truncated.Stack) != len(r.Stack)-1 {
+		t.Fatalf("Expected all but the last frame from truncated details: %+v", truncated)
+	}
+}
+
+func TestTextFallback(t *testing.T) {
+	tests := []struct {
+		input    string
+		location string
+		file     string
+		message  string
+	}{
+		{"main.go:57: message: with colon", "main.go:57", "main.go", " message: with colon"},
+		{`C:\src\app\main.go:12: windows`, "main.go:12", `C:\src\app\main.go`, " windows"},
+		{"no location: here", "-", "", "no location: here"},
+		{"prefix main.go:", "-", "", "prefix main.go:"},
+	}
+	for _, test := range tests {
+		e := NewLogEntry(test.input, InfoLevel)
+		if e.Location != test.location || e.File != test.file || e.Message != test.message {
+			t.Errorf("Incorrect entry for %q: %q %q %q", test.input, e.Location, e.File, e.Message)
+		}
+	}
+}
+
+func TestGoroutineID(t *testing.T) {
+	ids := make(chan uint64)
+	go func() { ids <- goroutineID() }()
+	if id, other := goroutineID(), <-ids; id == 0 || other == 0 || id == other {
+		t.Fatalf("Expected different goroutine IDs but got %d and %d", id, other)
+	}
+}
@@ -0,0 +1,301 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BacklogScope is the scope of the flight recorder's backlogs
+type BacklogScope int
+
+// The scopes for the flight recorder
+const (
+	// GlobalBacklog keeps one backlog for the entire process. An error
+	// flushes the latest messages from all goroutines.
+	GlobalBacklog BacklogScope = iota
+	// GoroutineBacklog keeps one backlog for each goroutine. An error
+	// flushes the messages logged by the same goroutine.
+	GoroutineBacklog
+	// ContextBacklog only keeps messages logged with the context-aware
+	// functions (DebugContext and so on) using a context from WithBacklog.
+	// An error logged with the same context flushes the messages.
+	ContextBacklog
+)
+
+// Default limits for the flight recorder
+const (
+	DefaultBacklogEntries    = 100
+	DefaultBacklogGoroutines = 1000
+)
+
+// FlightRecorderConfig is the configuration for the flight recorder
+type FlightRecorderConfig struct {
+	// Scope is the scope of the backlogs.
+	Scope BacklogScope
+	// Entries is the number of messages kept in each backlog. The default
+	// is DefaultBacklogEntries.
+	Entries int
+	// MaxFlush is the maximum number of messages flushed for each error.
+	// The latest messages are flushed. 0 flushes the entire backlog.
+	MaxFlush int
+	// MaxAge is the maximum age of the flushed messages. 0 is no limit.
+	MaxAge time.Duration
+	// MaxGoroutines is the maximum number of backlogs kept for the
+	// GoroutineBacklog scope. The backlog that has been unused for the
+	// longest time is discarded when a new one is needed. The default is
+	// DefaultBacklogGoroutines.
+	MaxGoroutines int
+}
+
+// flightRecorder holds the backlogs for the messages below the log level
+type flightRecorder struct {
+	config     FlightRecorderConfig
+	mutex      sync.Mutex
+	global     *backlog
+	goroutines map[uint64]*backlog
+}
+
+// recorder holds the active *flightRecorder. A nil recorder disables the
+// flight recorder.
+var recorder atomic.Value
+
+func init() {
+	recorder.Store((*flightRecorder)(nil))
+}
+
+// EnableFlightRecorder keeps the messages below the log level in a small
+// backlog and writes them to the outputs before the next error is logged.
+// The flushed messages keep their original time and location.
+func EnableFlightRecorder(config FlightRecorderConfig) {
+	if config.Entries <= 0 {
+		config.Entries = DefaultBacklogEntries
+	}
+	if config.MaxFlush <= 0 || config.MaxFlush > config.Entries {
+		config.MaxFlush = config.Entries
+	}
+	if config.MaxGoroutines <= 0 {
+		config.MaxGoroutines = DefaultBacklogGoroutines
+	}
+	recorder.Store(&flightRecorder{
+		config:     config,
+		global:     newBacklog(config.Entries),
+		goroutines: make(map[uint64]*backlog),
+	})
+}
+
+// DisableFlightRecorder turns off the flight recorder. The backlogs are
+// discarded.
+func DisableFlightRecorder() {
+	recorder.Store((*flightRecorder)(nil))
+}
+
+// WithBacklog returns a copy of the context with a new backlog for the flight
+// recorder. Messages logged with the context-aware functions and the context
+// are kept in this backlog regardless of the scope, and an error logged with
+// the context flushes them.
+func WithBacklog(ctx context.Context) context.Context {
+	entries := DefaultBacklogEntries
+	if r := currentRecorder(); r != nil {
+		entries = r.config.Entries
+	}
+	return context.WithValue(ctx, backlogKey, newBacklog(entries))
+}
+
+func currentRecorder() *flightRecorder {
+	return recorder.Load().(*flightRecorder)
+}
+
+// record adds a record to the backlog. The calldepth parameter is relative
+// to the caller of record, the same way as for emit.
+func (f *flightRecorder) record(ctx context.Context, calldepth int, r Record) {
+	b := f.backlog(ctx, true)
+	if b == nil {
+		return
+	}
+	r.redact()
+	r.setCaller(callerDepth(calldepth))
+	b.add(r)
+}
+
+// flush writes the backlog for the context or goroutine to the outputs
+func (f *flightRecorder) flush(ctx context.Context, o *outputs) {
+	b := f.backlog(ctx, false)
+	if b == nil {
+		return
+	}
+	records := b.take(f.config.MaxFlush, f.config.MaxAge)
+	if len(records) == 0 {
+		return
+	}
+	mirror := currentMirror()
+	for i := range records {
+		r := &records[i]
+		level := r.Level
+		if level > ErrorLevel {
+			level = ErrorLevel
+		}
+		writeRecord(o.levels[level], r)
+		if mirror != nil {
+			mirror.WriteRecord(r)
+		}
+	}
+}
+
+// backlog returns the backlog to use for the context. A backlog for the
+// goroutine is created if create is set.
+func (f *flightRecorder) backlog(ctx context.Context, create bool) *backlog {
+	if ctx != nil {
+		if b, ok := ctx.Value(backlogKey).(*backlog); ok {
+			return b
+		}
+	}
+	switch f.config.Scope {
+	case GlobalBacklog:
+		return f.global
+	case GoroutineBacklog:
+		id := goroutineID()
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		b, ok := f.goroutines[id]
+		if !ok && create {
+			if len(f.goroutines) >= f.config.MaxGoroutines {
+				f.discardOldest()
+			}
+			b = newBacklog(f.config.Entries)
+			f.goroutines[id] = b
+		}
+		return b
+	}
+	return nil
+}
+
+// discardOldest removes the goroutine backlog that was used least recently.
+// The mutex must be held.
+func (f *flightRecorder) discardOldest() {
+	var oldest uint64
+	var oldestTime int64
+	for id, b := range f.goroutines {
+		if used := atomic.LoadInt64(&b.used); oldestTime == 0 || used < oldestTime {
+			oldest, oldestTime = id, used
+		}
+	}
+	delete(f.goroutines, oldest)
+}
+
+// backlog is a ring buffer of records
+type backlog struct {
+	mutex   sync.Mutex
+	records []Record
+	next    int
+	count   int
+	used    int64 // The time the backlog was last used (Unix nanoseconds)
+}
+
+func newBacklog(entries int) *backlog {
+	return &backlog{records: make([]Record, entries)}
+}
+
+func (b *backlog) add(r Record) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.records[b.next] = r
+	b.next = (b.next + 1) % len(b.records)
+	if b.count < len(b.records) {
+		b.count++
+	}
+	atomic.StoreInt64(&b.used, r.Time.UnixNano())
+}
+
+// take removes all records from the backlog and returns the latest ones,
+// oldest first. At most max records that are newer than maxAge are returned.
+func (b *backlog) take(max int, maxAge time.Duration) []Record {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	n := b.count
+	if n > max {
+		n = max
+	}
+	ret := make([]Record, 0, n)
+	limit := time.Now().Add(-maxAge)
+	for i := n; i > 0; i-- {
+		r := b.records[(b.next-i+len(b.records))%len(b.records)]
+		if maxAge <= 0 || !r.Time.Before(limit) {
+			ret = append(ret, r)
+		}
+	}
+	for i := range b.records {
+		b.records[i] = Record{}
+	}
+	b.next, b.count = 0, 0
+	return ret
+}
+
+// writeRecord writes a record to the logger's output. Outputs that aren't
+// RecordWriters get the record formatted with the logger's prefix and flags.
+func writeRecord(l *log.Logger, r *Record) {
+	if w, ok := l.Writer().(RecordWriter); ok {
+		w.WriteRecord(r)
+		return
+	}
+	l.Writer().Write(formatRecord(l.Prefix(), l.Flags(), r))
+}
+
+// formatRecord formats the record the same way as log.Logger does
+func formatRecord(prefix string, flags int, r *Record) []byte {
+	var buf []byte
+	if flags&log.Lmsgprefix == 0 {
+		buf = append(buf, prefix...)
+	}
+	t := r.Time
+	if flags&log.LUTC != 0 {
+		t = t.UTC()
+	}
+	if flags&log.Ldate != 0 {
+		buf = t.AppendFormat(buf, "2006/01/02 ")
+	}
+	if flags&log.Lmicroseconds != 0 {
+		buf = t.AppendFormat(buf, "15:04:05.000000 ")
+	} else if flags&log.Ltime != 0 {
+		buf = t.AppendFormat(buf, "15:04:05 ")
+	}
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		file, line := r.File, r.Line
+		if file == "" {
+			file, line = "???", 0
+		} else if flags&log.Lshortfile != 0 {
+			file = shortFile(file)
+		}
+		buf = append(buf, file...)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(line), 10)
+		buf = append(buf, ": "...)
+	}
+	if flags&log.Lmsgprefix != 0 {
+		buf = append(buf, prefix...)
+	}
+	msg := r.text()
+	buf = append(buf, msg...)
+	if len(msg) == 0 || msg[len(msg)-1] != '\n' {
+		buf = append(buf, '\n')
+	}
+	return buf
+}
@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// messages returns the messages in the memory logs, ordered by sequence
+// number
+func messages(logs []*MemoryLogger) []string {
+	var ret []string
+	for _, e := range logs[0].Merge(logs[1:]...) {
+		ret = append(ret, e.Message)
+	}
+	return ret
+}
+
+func TestFlightRecorder(t *testing.T) {
+	logs := NewMemoryLoggers(20)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(WarningLevel)
+	EnableFlightRecorder(FlightRecorderConfig{Entries: 3})
+	defer DisableFlightRecorder()
+
+	Debug("lost")
+	Info("info 1")
+	Debug("debug 2")
+	Debug("debug 3")
+	if len(messages(logs)) != 0 {
+		t.Fatal("Expected messages to be kept in the backlog")
+	}
+	Error("failure")
+	got := strings.Join(messages(logs), ",")
+	if got != "info 1,debug 2,debug 3,failure" {
+		t.Fatalf("Unexpected messages: %s", got)
+	}
+	flushed := logs[InfoLevel].Entries()[0]
+	if !strings.HasPrefix(flushed.Location, "recorder_test.go:") || !flushed.Time.Before(logs[ErrorLevel].Entries()[0].Time) {
+		t.Fatalf("Flushed entry should keep its location and time: %+v", flushed)
+	}
+
+	Error("second failure")
+	if len(messages(logs)) != 5 {
+		t.Fatal("The backlog should be empty after it is flushed")
+	}
+}
+
+func TestFlightRecorderComponents(t *testing.T) {
+	logs := NewMemoryLoggers(20)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(DebugLevel)
+	defer SetLogLevel(WarningLevel)
+	SetComponentLevels(map[string]uint{"store": WarningLevel})
+	defer SetComponentLevels(nil)
+	EnableFlightRecorder(FlightRecorderConfig{Entries: 3})
+	defer DisableFlightRecorder()
+
+	c := NewComponent("store")
+	c.Debug("component debug")
+	if len(messages(logs)) != 0 {
+		t.Fatal("Expected the component message to be kept in the backlog")
+	}
+	c.Err(errors.New("component failure"))
+	got := strings.Join(messages(logs), ",")
+	if got != "component debug,component failure" {
+		t.Fatalf("Unexpected messages: %s", got)
+	}
+	if e := logs[DebugLevel].Entries()[0]; e.Component != "store" {
+		t.Fatalf("Flushed entry should keep the component: %+v", e)
+	}
+}
+
+func TestFlightRecorderLimits(t *testing.T) {
+	logs := NewMemoryLoggers(20)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(WarningLevel)
+	EnableFlightRecorder(FlightRecorderConfig{Entries: 10, MaxFlush: 2, MaxAge: time.Hour})
+	defer DisableFlightRecorder()
+
+	for _, m := range []string{"a", "b", "c", "d"} {
+		Debug("%s", m)
+	}
+	Error("e")
+	if got := strings.Join(messages(logs), ","); got != "c,d,e" {
+		t.Fatalf("Expected only the latest messages to be flushed but got %s", got)
+	}
+}
+
+func TestFlightRecorderGoroutineScope(t *testing.T) {
+	logs := NewMemoryLoggers(20)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(WarningLevel)
+	EnableFlightRecorder(FlightRecorderConfig{Scope: GoroutineBacklog, MaxGoroutines: 2})
+	defer DisableFlightRecorder()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		Debug("other goroutine")
+	}()
+	wg.Wait()
+	Debug("this goroutine")
+	Error("failure")
+	if got := strings.Join(messages(logs), ","); got != "this goroutine,failure" {
+		t.Fatalf("Expected only this goroutine's backlog but got %s", got)
+	}
+}
+
+func TestFlightRecorderContextScope(t *testing.T) {
+	logs := NewMemoryLoggers(20)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(WarningLevel)
+	EnableFlightRecorder(FlightRecorderConfig{Scope: ContextBacklog})
+	defer DisableFlightRecorder()
+
+	ctx := WithBacklog(context.Background())
+	other := WithBacklog(context.Background())
+	DebugContext(ctx, "in request")
+	DebugContext(other, "in other request")
+	Debug("without context")
+	Error("unrelated failure")
+	ErrorContext(ctx, "request failure")
+	if got := strings.Join(messages(logs), ","); got != "unrelated failure,in request,request failure" {
+		t.Fatalf("Expected only the request's backlog but got %s", got)
+	}
+}
+
+func TestFormatRecord(t *testing.T) {
+	r := &Record{
+		Time:    time.Date(2018, 3, 4, 5, 6, 7, 8000, time.UTC),
+		File:    "/src/app/main.go",
+		Line:    42,
+		Message: "message",
+	}
+	tests := []struct {
+		prefix   string
+		flags    int
+		expected string
+	}{
+		{"INFO ", log.LstdFlags | log.Lshortfile | log.LUTC, "INFO 2018/03/04 05:06:07 main.go:42: message\n"},
+		{"", log.Lmicroseconds | log.Llongfile | log.LUTC, "05:06:07.000008 /src/app/main.go:42: message\n"},
+		{"> ", log.Lshortfile | log.Lmsgprefix, "main.go:42: > message\n"},
+	}
+	for _, test := range tests {
+		if got := string(formatRecord(test.prefix, test.flags, r)); got != test.expected {
+			t.Errorf("Expected %q but got %q", test.expected, got)
+		}
+	}
+}
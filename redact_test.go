@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+	defer DisableRedaction()
+	if err := EnableRedaction(RedactionConfig{Patterns: []string{"("}}); err == nil {
+		t.Fatal("Expected error for invalid pattern")
+	}
+	if redact("a@example.com") != "a@example.com" {
+		t.Fatal("Redaction should be disabled after invalid configuration")
+	}
+
+	if err := EnableRedaction(RedactionConfig{
+		Detectors: RedactAll,
+		Patterns:  []string{`device-(\d+)`},
+		Fields:    []string{"password", "api_key"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	marker := regexp.MustCompile(`\[REDACTED:[0-9a-f]{8}\]`)
+	tests := []struct {
+		input    string
+		redacted int
+		kept     string
+	}{
+		{"Authorization: Bearer abc.def-123", 1, "Bearer "},
+		{"connecting to postgres://user:secret@db:5432/x", 1, "postgres://user:"},
+		{"user john.doe@example.com registered", 1, "user "},
+		{"request from 10.0.0.1 and 2001:db8::1", 2, "request from "},
+		{"deleted device-1234", 1, "deleted device-"},
+		{`password=hunter2 {"api_key": "k3y"}`, 2, "password="},
+		{"std::vector at 12:30:45 in v1.2.3", 0, "std::vector at 12:30:45 in v1.2.3"},
+	}
+	for _, test := range tests {
+		out := redact(test.input)
+		if n := len(marker.FindAllString(out, -1)); n != test.redacted {
+			t.Errorf("Expected %d redactions in %q but got %d: %q", test.redacted, test.input, n, out)
+		}
+		if !strings.Contains(out, test.kept) {
+			t.Errorf("Expected %q to be kept in %q", test.kept, out)
+		}
+	}
+	if redact("a@example.com") != redact("a@example.com") || redact("a@example.com") == redact("b@example.com") {
+		t.Fatal("Hashes should correlate values")
+	}
+
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	Error("Login failed for %s", "jane@example.com")
+	if msg := logs[ErrorLevel].Entries()[0].Message; strings.Contains(msg, "jane") {
+		t.Fatalf("Expected email to be redacted in memory log: %s", msg)
+	}
+}
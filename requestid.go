@@ -0,0 +1,181 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Header names used by the request ID handler
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceParentHeader = "traceparent"
+)
+
+// requestMarker is the detail line with the request ID. It follows the log
+// message in the text outputs when one of the context-aware functions are
+// used.
+const requestMarker = "\trequest "
+
+// maxRequestIDLength is the longest request ID accepted from clients
+const maxRequestIDLength = 128
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	traceParentKey
+	loggerKey
+	backlogKey
+)
+
+// WithRequestID returns a copy of the context with the request ID set
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID in the context. An empty string is
+// returned if there's no request ID.
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// TraceParent returns the W3C traceparent in the context. An empty string is
+// returned if there's no traceparent.
+func TraceParent(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tp, _ := ctx.Value(traceParentKey).(string)
+	return tp
+}
+
+// NewRequestIDHandler returns a http.Handler that sets the request ID and
+// W3C traceparent in the request context before calling the next handler.
+// The X-Request-ID header is used if the client supplies a valid one,
+// otherwise a new request ID is generated. The trace ID is kept from the
+// client's traceparent header but a new parent ID is generated for this
+// request. Both headers are set on the response.
+func NewRequestIDHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = randomHex(8)
+		}
+		traceID, flags := "", "00"
+		if fields := parseTraceParent(r.Header.Get(TraceParentHeader)); fields != nil {
+			traceID, flags = fields[1], fields[3]
+		} else {
+			traceID = randomHex(16)
+		}
+		traceParent := fmt.Sprintf("00-%s-%s-%s", traceID, randomHex(8), flags)
+
+		w.Header().Set(RequestIDHeader, id)
+		w.Header().Set(TraceParentHeader, traceParent)
+		ctx := WithRequestID(r.Context(), id)
+		ctx = context.WithValue(ctx, traceParentKey, traceParent)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// DebugContext is the same as Debug but includes the request ID from the
+// context. The message is written to the Logger in the context if there is
+// one.
+func DebugContext(ctx context.Context, format string, v ...interface{}) {
+	outputContextf(ctx, 2, DebugLevel, format, v)
+}
+
+// InfoContext is the same as Info but includes the request ID from the
+// context. The message is written to the Logger in the context if there is
+// one.
+func InfoContext(ctx context.Context, format string, v ...interface{}) {
+	outputContextf(ctx, 2, InfoLevel, format, v)
+}
+
+// WarningContext is the same as Warning but includes the request ID from the
+// context. The message is written to the Logger in the context if there is
+// one.
+func WarningContext(ctx context.Context, format string, v ...interface{}) {
+	outputContextf(ctx, 2, WarningLevel, format, v)
+}
+
+// ErrorContext is the same as Error but includes the request ID from the
+// context. The message is written to the Logger in the context if there is
+// one.
+func ErrorContext(ctx context.Context, format string, v ...interface{}) {
+	outputContextf(ctx, 2, ErrorLevel, format, v)
+}
+
+// validRequestID checks if a client supplied request ID can be used. It must
+// be non-empty, reasonably short and only contain printable ASCII without
+// spaces.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, ch := range id {
+		if ch <= ' ' || ch > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTraceParent splits a version 00 traceparent header into the version,
+// trace ID, parent ID and flags fields. Nil is returned if the header is
+// invalid.
+func parseTraceParent(tp string) []string {
+	fields := strings.Split(tp, "-")
+	if len(fields) != 4 || fields[0] != "00" {
+		return nil
+	}
+	for i, length := range []int{2, 32, 16, 2} {
+		if len(fields[i]) != length || !isLowerHex(fields[i]) {
+			return nil
+		}
+	}
+	if fields[1] == strings.Repeat("0", 32) || fields[2] == strings.Repeat("0", 16) {
+		return nil
+	}
+	return fields
+}
+
+func isLowerHex(s string) bool {
+	for _, ch := range s {
+		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes as a hex string
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("unable to read random bytes: %v", err))
+	}
+	return hex.EncodeToString(buf)
+}
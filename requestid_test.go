@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDHandler(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(DebugLevel)
+	defer SetLogLevel(WarningLevel)
+
+	var seenID, seenTrace string
+	h := NewRequestIDHandler(NewAccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenID = RequestID(r.Context())
+		seenTrace = TraceParent(r.Context())
+		WarningContext(r.Context(), "Handling request")
+	}), CommonLogFormat))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "client-id")
+	req.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if seenID != "client-id" || rec.Header().Get(RequestIDHeader) != "client-id" {
+		t.Fatalf("Expected client request ID to be used but got %s", seenID)
+	}
+	if !strings.HasPrefix(seenTrace, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || !strings.HasSuffix(seenTrace, "-01") ||
+		strings.Contains(seenTrace, "00f067aa0ba902b7") || parseTraceParent(seenTrace) == nil {
+		t.Fatalf("Expected trace ID to be kept with a new parent ID: %s", seenTrace)
+	}
+	if rec.Header().Get(TraceParentHeader) != seenTrace {
+		t.Fatal("Expected traceparent to be set on the response")
+	}
+
+	warnings := logs[WarningLevel].Entries()
+	if len(warnings) != 1 || warnings[0].RequestID != "client-id" || strings.TrimSpace(warnings[0].Message) != "Handling request" {
+		t.Fatalf("Expected entry with request ID: %+v", warnings)
+	}
+	access := logs[InfoLevel].Entries()
+	if len(access) != 1 || access[0].RequestID != "client-id" {
+		t.Fatalf("Expected access log with request ID: %+v", access)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "not valid")
+	req.Header.Set(TraceParentHeader, "garbage")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if len(seenID) != 16 || seenID == "not valid" {
+		t.Fatalf("Expected generated request ID but got %q", seenID)
+	}
+	if parseTraceParent(seenTrace) == nil {
+		t.Fatalf("Expected generated traceparent but got %q", seenTrace)
+	}
+}
@@ -0,0 +1,130 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import "time"
+
+// maxReusedText is the largest text buffer that is reused in the ring buffer.
+// Larger buffers are released when a smaller entry is written to the slot.
+const maxReusedText = 64 * 1024
+
+// MemoryStats is the retention statistics for a memory log
+type MemoryStats struct {
+	Entries        int    // The number of entries kept
+	Bytes          int64  // The size of the messages kept
+	EvictedByCount uint64 // Entries overwritten when the log is full
+	EvictedBySize  uint64 // Entries evicted by the byte limit
+	EvictedByAge   uint64 // Entries evicted by the age limit
+	EvictedBytes   uint64 // The size of all evicted entries
+	Rejected       uint64 // Entries larger than the byte limit on their own
+}
+
+// retention is the limits and statistics for a memory log
+type retention struct {
+	maxBytes int64
+	maxAge   time.Duration
+	stats    MemoryStats
+}
+
+// SetRetention sets the limits for the total size of the messages and the
+// age of the entries. The oldest entries are evicted when one of the limits
+// is exceeded. An entry larger than maxBytes on its own is rejected rather
+// than evicting every other entry; it is counted in Rejected. A value of 0
+// means no limit. The number of entries is always limited by the
+// size of the ring buffer.
+func (m *MemoryLogger) SetRetention(maxBytes int64, maxAge time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.retention.maxBytes = maxBytes
+	m.retention.maxAge = maxAge
+	m.enforce(time.Now())
+}
+
+// Stats returns the retention statistics
+func (m *MemoryLogger) Stats() MemoryStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.expire(time.Now())
+	ret := m.retention.stats
+	ret.Entries = int(m.count + 1 - m.first)
+	return ret
+}
+
+// SetRetention sets the retention limits for all of the logs in the store.
+// See MemoryLogger.SetRetention for details.
+func (s *MemoryStore) SetRetention(maxBytes int64, maxAge time.Duration) {
+	for _, l := range s.logs {
+		l.SetRetention(maxBytes, maxAge)
+	}
+}
+
+// reject returns true if an entry of the size is larger than the byte limit
+// on its own. The entry is counted and should be dropped before a slot is
+// used for it. The mutex must be held.
+func (m *MemoryLogger) reject(size int64) bool {
+	r := &m.retention
+	if r.maxBytes <= 0 || size <= r.maxBytes {
+		return false
+	}
+	r.stats.Rejected++
+	return true
+}
+
+// retain adds the size of the new entry and enforces the limits. The mutex
+// must be held.
+func (m *MemoryLogger) retain(slot *memorySlot, size int64, now time.Time) {
+	slot.size = size
+	m.retention.stats.Bytes += size
+	m.enforce(now)
+}
+
+// enforce evicts the oldest entries until the log is within the limits. The
+// mutex must be held.
+func (m *MemoryLogger) enforce(now time.Time) {
+	r := &m.retention
+	for r.maxBytes > 0 && r.stats.Bytes > r.maxBytes && m.first <= m.count {
+		m.evict(m.slot(m.first), &r.stats.EvictedBySize)
+	}
+	m.expire(now)
+}
+
+// expire evicts the entries that are older than the age limit. The mutex must
+// be held.
+func (m *MemoryLogger) expire(now time.Time) {
+	r := &m.retention
+	if r.maxAge <= 0 {
+		return
+	}
+	limit := now.Add(-r.maxAge)
+	for m.first <= m.count && m.slot(m.first).record.Time.Before(limit) {
+		m.evict(m.slot(m.first), &r.stats.EvictedByAge)
+	}
+}
+
+// evict removes the oldest entry, which is in the slot. The record and any
+// large text buffer is released so the memory can be reclaimed. The mutex
+// must be held.
+func (m *MemoryLogger) evict(slot *memorySlot, counter *uint64) {
+	*counter++
+	m.retention.stats.Bytes -= slot.size
+	m.retention.stats.EvictedBytes += uint64(slot.size)
+	m.first = slot.pos + 1
+	slot.record = Record{}
+	slot.size = 0
+	if cap(slot.text) > maxReusedText {
+		slot.text = nil
+	}
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetentionBySize(t *testing.T) {
+	ml := NewMemoryLogger(100, DebugLevel)
+	ml.SetRetention(1000, 0)
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(ml, "main.go:%d: %s\n", i, strings.Repeat("x", 90))
+	}
+	// Each line is 102 bytes so only 9 fit
+	stats := ml.Stats()
+	if stats.Entries != 9 || stats.Bytes != 918 || stats.EvictedBySize != 1 || stats.EvictedBytes != 102 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+	if entries := ml.Entries(); len(entries) != 9 || entries[0].Location != "main.go:1" {
+		t.Fatalf("Expected the oldest entry to be evicted: %+v", entries[0])
+	}
+
+	// A large dump is rejected and the existing entries are kept
+	fmt.Fprintf(ml, "main.go:99: %s\n", strings.Repeat("x", 2000))
+	ml.WriteRecord(&Record{Time: time.Now(), Message: strings.Repeat("x", 2000)})
+	if stats := ml.Stats(); stats.Entries != 9 || stats.Bytes != 918 || stats.EvictedBySize != 1 || stats.Rejected != 2 {
+		t.Fatalf("Unexpected stats after large entry: %+v", stats)
+	}
+	if entries := ml.Entries(); len(entries) != 9 || entries[0].Location != "main.go:1" {
+		t.Fatalf("Expected the entries to survive: %+v", entries)
+	}
+	fmt.Fprintf(ml, "main.go:100: small\n")
+	if entries := ml.Entries(); len(entries) != 10 || entries[0].Location != "main.go:1" || entries[9].Location != "main.go:100" {
+		t.Fatalf("Expected the small entry to be kept: %+v", entries)
+	}
+}
+
+func TestRetentionByAge(t *testing.T) {
+	ml := NewMemoryLogger(100, DebugLevel)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		ml.WriteRecord(&Record{Time: now.Add(time.Duration(i-10) * time.Minute), Message: "old"})
+	}
+	ml.WriteRecord(&Record{Time: now, Message: "new"})
+	ml.SetRetention(0, 5*time.Minute)
+	stats := ml.Stats()
+	if stats.Entries != 1 || stats.EvictedByAge != 5 || stats.Bytes != 3 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+	if entries := ml.Query(Query{}); len(entries) != 1 || entries[0].Message != "new" {
+		t.Fatalf("Expected only the new entry but got %+v", entries)
+	}
+}
+
+func TestRetentionByCount(t *testing.T) {
+	ml := NewMemoryLogger(3, DebugLevel)
+	for i := 0; i < 5; i++ {
+		ml.WriteRecord(&Record{Time: time.Now(), Message: "entry"})
+	}
+	stats := ml.Stats()
+	if stats.Entries != 3 || stats.Bytes != 15 || stats.EvictedByCount != 2 || stats.EvictedBytes != 10 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,93 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"sync/atomic"
+	"time"
+)
+
+// sampler limits the number of messages for one level. The first Initial
+// messages in each interval are logged, then every Thereafter message.
+type sampler struct {
+	initial    uint64
+	thereafter uint64
+	interval   int64
+	window     int64
+	count      uint64
+	dropped    uint64
+}
+
+// SetSampling limits the number of debug, info and warning messages logged
+// for each level. The first initial messages in each interval are logged,
+// then every thereafter message. If thereafter is 0 the remaining messages in
+// the interval are dropped. Error messages are never dropped. An interval of
+// 0 turns off sampling.
+func SetSampling(initial, thereafter int, interval time.Duration) {
+	samplers := newSamplers(initial, thereafter, interval)
+	updateSettings(func(s *settings) {
+		s.samplers = samplers
+	})
+}
+
+// newSamplers creates the samplers for the levels below ErrorLevel. Errors
+// are never sampled.
+func newSamplers(initial, thereafter int, interval time.Duration) *[ErrorLevel]*sampler {
+	ret := &[ErrorLevel]*sampler{}
+	if interval > 0 {
+		for i := range ret {
+			ret[i] = &sampler{initial: uint64(initial), thereafter: uint64(thereafter), interval: int64(interval)}
+		}
+	}
+	return ret
+}
+
+// SampledCount returns the number of messages dropped by the sampling since
+// it was last set.
+func SampledCount() uint64 {
+	var ret uint64
+	for _, s := range currentSettings.Load().(*settings).samplers {
+		if s != nil {
+			ret += atomic.LoadUint64(&s.dropped)
+		}
+	}
+	return ret
+}
+
+// sampled returns true if a message at the level should be logged
+func (s *settings) sampled(level uint) bool {
+	if level >= ErrorLevel {
+		return true
+	}
+	sm := s.samplers[level]
+	if sm == nil {
+		return true
+	}
+	return sm.sample(time.Now().UnixNano())
+}
+
+func (s *sampler) sample(now int64) bool {
+	window := now / s.interval
+	if old := atomic.LoadInt64(&s.window); old != window && atomic.CompareAndSwapInt64(&s.window, old, window) {
+		atomic.StoreUint64(&s.count, 0)
+	}
+	n := atomic.AddUint64(&s.count, 1)
+	if n <= s.initial || (s.thereafter > 0 && (n-s.initial)%s.thereafter == 0) {
+		return true
+	}
+	atomic.AddUint64(&s.dropped, 1)
+	return false
+}
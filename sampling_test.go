@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	s := &sampler{initial: 3, thereafter: 4, interval: int64(time.Second)}
+	logged := 0
+	for i := 0; i < 15; i++ {
+		if s.sample(0) {
+			logged++
+		}
+	}
+	// 3 initial and then #7, #11 and #15
+	if logged != 6 || s.dropped != 9 {
+		t.Fatalf("Expected 6 messages logged but got %d (%d dropped)", logged, s.dropped)
+	}
+	if !s.sample(int64(time.Second)) {
+		t.Fatal("Expected counter to reset in the next interval")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	setLogLevel(t, WarningLevel)
+	logs := NewMemoryLoggers(100)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetSampling(2, 0, time.Hour)
+	defer SetSampling(0, 0, 0)
+
+	for i := 0; i < 10; i++ {
+		Warning("Warning %d", i)
+		Error("Error %d", i)
+	}
+	if n := len(logs[WarningLevel].Entries()); n != 2 {
+		t.Fatalf("Expected 2 warnings but got %d", n)
+	}
+	if n := len(logs[ErrorLevel].Entries()); n != 10 {
+		t.Fatalf("Errors should not be sampled but got %d", n)
+	}
+	if SampledCount() != 8 {
+		t.Fatalf("Expected 8 dropped messages but got %d", SampledCount())
+	}
+}
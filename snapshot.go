@@ -0,0 +1,299 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// snapshotMagic is the header for snapshot files. The entries follow in a
+// gzip stream.
+const snapshotMagic = "EELOGS02"
+
+// maxSnapshotString is the longest string accepted when reading snapshots
+const maxSnapshotString = 64 * 1024 * 1024
+
+// WriteSnapshot writes the entries in the memory logs to w. The entries are
+// ordered by sequence number so a snapshot of the logs for several levels
+// can be restored in the same order.
+func WriteSnapshot(w io.Writer, logs ...*MemoryLogger) error {
+	var slots []memorySlot
+	for _, l := range logs {
+		slots = append(slots, l.snapshot(1, math.MaxUint64)...)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].seq < slots[j].seq })
+
+	if _, err := io.WriteString(w, snapshotMagic); err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUint := func(v uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf, v)])
+	}
+	putString := func(s string) {
+		putUint(uint64(len(s)))
+		bw.WriteString(s)
+	}
+	for i := range slots {
+		r := &slots[i].record
+		flags := uint64(0)
+		if slots[i].isText {
+			flags = 1
+		}
+		putUint(flags)
+		bw.Write(buf[:binary.PutVarint(buf, r.Time.UnixNano())])
+		putUint(uint64(r.Level))
+		putString(r.File)
+		putUint(uint64(r.Line))
+		putString(r.Function)
+		putUint(r.Goroutine)
+		putString(r.Message)
+		if !slots[i].isText {
+			putString(string(r.appendDetails(nil)))
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadSnapshot reads a snapshot and adds the entries to the logs. If there is
+// one log for each level (as returned by NewMemoryLoggers) the entries are
+// added to the log for their level, otherwise all entries are added to the
+// first log. The entries get new sequence numbers so they come before
+// entries written later. Nothing is added if the snapshot is invalid.
+func ReadSnapshot(r io.Reader, logs ...*MemoryLogger) error {
+	if len(logs) == 0 {
+		return errors.New("no memory logs to restore to")
+	}
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != snapshotMagic {
+		return errors.New("not a log snapshot")
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	// The entries are restored once the entire snapshot is read so the logs
+	// are left as they are if the snapshot is invalid.
+	br := bufio.NewReader(zr)
+	var slots []memorySlot
+	for {
+		slot, err := readSnapshotSlot(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid log snapshot: %v", err)
+		}
+		slots = append(slots, slot)
+	}
+	for _, slot := range slots {
+		restoreTarget(logs, slot.record.Level).restore(slot)
+	}
+	return nil
+}
+
+// restoreTarget returns the log for restored entries at the level
+func restoreTarget(logs []*MemoryLogger, level uint) *MemoryLogger {
+	if len(logs) > int(ErrorLevel) && level <= ErrorLevel {
+		return logs[level]
+	}
+	return logs[0]
+}
+
+// readSnapshotSlot reads a single entry. io.EOF is returned if there are no
+// more entries.
+func readSnapshotSlot(br *bufio.Reader) (memorySlot, error) {
+	var slot memorySlot
+	var err error
+	getUint := func() uint64 {
+		if err != nil {
+			return 0
+		}
+		var v uint64
+		v, err = binary.ReadUvarint(br)
+		return v
+	}
+	getString := func() string {
+		n := getUint()
+		if err != nil {
+			return ""
+		}
+		if n > maxSnapshotString {
+			err = fmt.Errorf("string too long (%d bytes)", n)
+			return ""
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(br, b)
+		return string(b)
+	}
+	flags := getUint()
+	if err == io.EOF {
+		return slot, err
+	}
+	var nanos int64
+	if err == nil {
+		nanos, err = binary.ReadVarint(br)
+	}
+	slot.isText = flags&1 != 0
+	slot.record.Time = time.Unix(0, nanos)
+	slot.record.Level = uint(getUint())
+	slot.record.File = getString()
+	slot.record.Line = int(getUint())
+	slot.record.Function = getString()
+	slot.record.Goroutine = getUint()
+	slot.record.Message = getString()
+	if !slot.isText {
+		slot.record.readDetails([]byte(getString()))
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return slot, err
+}
+
+// restore adds an entry from a snapshot
+func (m *MemoryLogger) restore(s memorySlot) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	slot := m.next()
+	slot.record = s.record
+	slot.isText = s.isText
+	if s.isText {
+		slot.text = append(slot.text[:0], s.record.Message...)
+		slot.record.Message = ""
+	}
+	m.retain(slot, int64(len(s.record.Message)), s.record.Time)
+}
+
+// SaveSnapshot writes a snapshot of the logs to the file. The snapshot is
+// written to a temporary file first and renamed so the previous snapshot is
+// kept if the snapshot can't be written. The file and the directory are
+// synced so the snapshot survives a crash.
+func SaveSnapshot(filename string, logs ...*MemoryLogger) error {
+	tmp := filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := WriteSnapshot(f, logs...); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(filename))
+}
+
+// syncDir syncs the directory so a file renamed into it is persisted
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// LoadSnapshot reads a snapshot file into the logs. See ReadSnapshot for
+// details.
+func LoadSnapshot(filename string, logs ...*MemoryLogger) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ReadSnapshot(f, logs...)
+}
+
+// autoSnapshot is the configuration for automatic snapshots
+type autoSnapshot struct {
+	filename string
+	logs     []*MemoryLogger
+}
+
+var (
+	currentAutoSnapshot *autoSnapshot
+	autoSnapshotMutex   sync.Mutex
+)
+
+// EnableAutoSnapshot saves a snapshot of the logs to the file when
+// RecoverAndLog recovers a panic and when SaveAutoSnapshot is called. The
+// package doesn't handle signals; call SaveAutoSnapshot from the
+// application's shutdown code to keep the logs across restarts.
+func EnableAutoSnapshot(filename string, logs ...*MemoryLogger) {
+	autoSnapshotMutex.Lock()
+	defer autoSnapshotMutex.Unlock()
+	currentAutoSnapshot = &autoSnapshot{filename: filename, logs: logs}
+}
+
+// DisableAutoSnapshot turns off the automatic snapshots
+func DisableAutoSnapshot() {
+	autoSnapshotMutex.Lock()
+	defer autoSnapshotMutex.Unlock()
+	currentAutoSnapshot = nil
+}
+
+// SaveAutoSnapshot saves the snapshot set up with EnableAutoSnapshot. This is
+// the shutdown hook for the automatic snapshots. Nothing is saved if the
+// automatic snapshots are disabled.
+func SaveAutoSnapshot() error {
+	autoSnapshotMutex.Lock()
+	defer autoSnapshotMutex.Unlock()
+	if currentAutoSnapshot == nil {
+		return nil
+	}
+	return SaveSnapshot(currentAutoSnapshot.filename, currentAutoSnapshot.logs...)
+}
+
+// saveAutoSnapshot saves the automatic snapshot if it is enabled. Errors are
+// written to stderr since this is used when the process is about to crash.
+func saveAutoSnapshot() {
+	if err := SaveAutoSnapshot(); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to save log snapshot: %v\n", err)
+	}
+}
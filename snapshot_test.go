@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	SetLogLevel(DebugLevel)
+	defer SetLogLevel(WarningLevel)
+	Debug("debug before restart")
+	Err(fmt.Errorf("wrapped: %w", errors.New("inner")))
+	InfoContext(WithRequestID(context.Background(), "r1"), "info before restart")
+	fmt.Fprintf(logs[WarningLevel], "foreign.go:12: text entry\n")
+
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, logs...); err != nil {
+		t.Fatal(err)
+	}
+	original := logs[0].Merge(logs[1:]...)
+
+	restored := NewMemoryLoggers(10)
+	Info("written before restoring")
+	if err := ReadSnapshot(bytes.NewReader(buf.Bytes()), restored...); err != nil {
+		t.Fatal(err)
+	}
+	entries := restored[0].Merge(restored[1:]...)
+	if len(entries) != len(original) {
+		t.Fatalf("Expected %d entries but got %d", len(original), len(entries))
+	}
+	for i, e := range entries {
+		o := original[i]
+		if e.Seq <= o.Seq || !e.Time.Equal(o.Time) || e.Level != o.Level || e.Location != o.Location ||
+			e.Message != o.Message || e.Function != o.Function || e.RequestID != o.RequestID || len(e.Causes) != len(o.Causes) {
+			t.Fatalf("Restored entry %d differs:\n%+v\n%+v", i, e, o)
+		}
+	}
+
+	single := NewMemoryLogger(10, DebugLevel)
+	if err := ReadSnapshot(bytes.NewReader(buf.Bytes()), single); err != nil || len(single.Entries()) != len(original) {
+		t.Fatalf("Expected all entries in a single log: %v", err)
+	}
+
+	for _, invalid := range [][]byte{nil, []byte("garbage"), buf.Bytes()[:len(buf.Bytes())-10]} {
+		logs := NewMemoryLoggers(10)
+		if err := ReadSnapshot(bytes.NewReader(invalid), logs...); err == nil {
+			t.Fatalf("Expected error for invalid snapshot %q", invalid)
+		}
+		if len(logs[0].Merge(logs[1:]...)) != 0 {
+			t.Fatal("Nothing should be restored from an invalid snapshot")
+		}
+	}
+}
+
+func TestAutoSnapshot(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "logs.snapshot")
+	logs := NewMemoryLoggers(10)
+	EnableMemoryLogger(logs)
+	defer EnableStderr(true)
+	EnableAutoSnapshot(filename, logs...)
+	defer DisableAutoSnapshot()
+	Flush()
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal("Flush should not save the snapshot")
+	}
+
+	fmt.Fprintf(logs[WarningLevel], "main.go:1: saved on shutdown\n")
+	if err := SaveAutoSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewMemoryLoggers(10)
+	if err := LoadSnapshot(filename, restored...); err != nil {
+		t.Fatal(err)
+	}
+	if e := restored[WarningLevel].Entries(); len(e) != 1 || !strings.Contains(e[0].Message, "saved on shutdown") {
+		t.Fatalf("Expected entry to be saved: %+v", e)
+	}
+
+	SetRepanic(false)
+	defer SetRepanic(true)
+	func() {
+		defer RecoverAndLog()
+		panic("saved on panic")
+	}()
+	restored = NewMemoryLoggers(10)
+	if err := LoadSnapshot(filename, restored...); err != nil {
+		t.Fatal(err)
+	}
+	if e := restored[ErrorLevel].Entries(); len(e) != 1 || !strings.Contains(e[0].Message, "saved on panic") {
+		t.Fatalf("Expected panic to be saved: %+v", e)
+	}
+
+	DisableAutoSnapshot()
+	if err := SaveAutoSnapshot(); err != nil {
+		t.Fatal("Nothing should be saved when disabled")
+	}
+}
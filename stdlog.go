@@ -0,0 +1,147 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// noCapture is the stdLogLevel value when the standard log isn't captured
+const noCapture = -1
+
+// stdLogLevel is the level the standard log package is captured at or
+// noCapture if it isn't captured.
+var stdLogLevel int32 = noCapture
+
+// The standard log package always writes through stdLogForwarder. The
+// forwarder picks the output when the message is written so the standard log
+// settings never have to change when the outputs are changed.
+func init() {
+	log.SetOutput(stdLogForwarder{})
+	log.SetFlags(0)
+	log.SetPrefix("")
+}
+
+// CaptureStdLog routes everything written through the standard log package
+// (log.Printf and friends) to the log at the specified level. The location
+// of the entries is the caller of the log package function. The capture is
+// kept when the outputs are changed with EnableStderr, EnableMemoryLogger or
+// EnableNamedSyslog.
+func CaptureStdLog(level uint) {
+	atomic.StoreInt32(&stdLogLevel, int32(level))
+}
+
+// ReleaseStdLog stops capturing the standard log package. The standard log
+// will write to the debug output afterwards.
+func ReleaseStdLog() {
+	atomic.StoreInt32(&stdLogLevel, noCapture)
+}
+
+// stdLogForwarder is the output for the standard log package. It writes to
+// the captured level or the standard log output if there's no capture.
+type stdLogForwarder struct{}
+
+func (stdLogForwarder) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	if level := atomic.LoadInt32(&stdLogLevel); level != noCapture {
+		output(uint(level), stdLogCallDepth(), msg)
+		return len(p), nil
+	}
+	s := acquireSettings()
+	defer s.release()
+	emit(s.outputs.std, currentMirror(), stdLogCallDepth(), Record{Level: DebugLevel, Message: msg})
+	return len(p), nil
+}
+
+// NewStdLogger returns a *log.Logger that writes to the log at the specified
+// level. Use this for libraries that accept a *log.Logger, like the ErrorLog
+// field in http.Server.
+func NewStdLogger(level uint) *log.Logger {
+	return log.New(&levelWriter{level: level}, "", 0)
+}
+
+// levelWriter is an io.Writer used as the output for a *log.Logger. The
+// location of the entry is the first caller outside of the log packages.
+type levelWriter struct {
+	level uint
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	output(w.level, stdLogCallDepth(), strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// stdLogCallDepth returns the call depth (for output and emit, relative to
+// the Write method of the log writer) of the first function outside of the
+// log and log/slog packages.
+func stdLogCallDepth() int {
+	pcs := make([]uintptr, maxStackDepth)
+	// Skip runtime.Callers, stdLogCallDepth and Write
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	depth := 2
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "log.") && !strings.HasPrefix(frame.Function, "log/slog.") {
+			return depth
+		}
+		if !more {
+			return 2
+		}
+		depth++
+	}
+}
+
+// PrintfLogger is an adapter for libraries that log through a Printf-style
+// interface. The messages are written to the log at the adapter's level.
+type PrintfLogger struct {
+	level uint
+}
+
+// NewPrintfLogger creates a PrintfLogger that logs at the specified level
+func NewPrintfLogger(level uint) *PrintfLogger {
+	return &PrintfLogger{level: level}
+}
+
+// Printf logs a message using fmt.Sprintf formatting
+func (p *PrintfLogger) Printf(format string, v ...interface{}) {
+	outputf(p.level, 2, "", format, v)
+}
+
+// Print logs a message using fmt.Sprint formatting
+func (p *PrintfLogger) Print(v ...interface{}) {
+	if wanted(p.level, "") {
+		output(p.level, 2, fmt.Sprint(v...))
+	}
+}
+
+// Println logs a message using fmt.Sprintln formatting
+func (p *PrintfLogger) Println(v ...interface{}) {
+	if wanted(p.level, "") {
+		output(p.level, 2, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	}
+}
+
+// Output writes a message to the log at the level. The calldepth parameter
+// works the same way as for log.Output; 1 is the caller of Output. This is
+// used by adapters for other logging interfaces.
+func Output(level uint, calldepth int, msg string) {
+	output(level, calldepth+1, msg)
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestCaptureStdLog(t *testing.T) {
+	logs := NewMemoryLoggers(10)
+	setMemoryOutput(t, logs)
+	setLogLevel(t, WarningLevel)
+	CaptureStdLog(WarningLevel)
+	t.Cleanup(ReleaseStdLog)
+
+	log.Printf("From the %s package", "log")
+	NewStdLogger(ErrorLevel).Println("From a std logger")
+	NewPrintfLogger(ErrorLevel).Printf("From a %s", "printf logger")
+
+	warnings := logs[WarningLevel].Entries()
+	if len(warnings) != 1 || strings.TrimSpace(warnings[0].Message) != "From the log package" {
+		t.Fatalf("Incorrect warning entries: %+v", warnings)
+	}
+	errs := logs[ErrorLevel].Entries()
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 error entries but got %d", len(errs))
+	}
+	for _, e := range append(warnings, errs...) {
+		if !strings.HasPrefix(e.Location, "stdlog_test.go:") {
+			t.Fatalf("Location should be the caller but is %s", e.Location)
+		}
+	}
+	if len(logs[DebugLevel].Entries()) != 0 {
+		t.Fatal("The standard log should not end up in the debug log")
+	}
+}
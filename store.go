@@ -0,0 +1,120 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"context"
+	"sync"
+)
+
+// MemoryQuotas is the number of entries kept for each level in a
+// MemoryStore. A quota of 0 keeps no entries for the level; the memory log
+// for the level discards everything written to it.
+type MemoryQuotas struct {
+	Debug   int
+	Info    int
+	Warning int
+	Error   int
+}
+
+// MemoryStore holds the memory logs for all levels behind one object. It is
+// a facade for one MemoryLogger per level rather than a single buffer; each
+// level has its own ring with its quota so debug entries never evict error
+// entries, and the entries are merged by sequence number when they are read.
+// Use EnableMemoryStore to log to the store.
+type MemoryStore struct {
+	logs []*MemoryLogger
+}
+
+// NewMemoryStore creates a memory store with the quotas. Quotas below 0 are
+// treated as 0.
+func NewMemoryStore(quotas MemoryQuotas) *MemoryStore {
+	ret := &MemoryStore{}
+	for level, quota := range []int{quotas.Debug, quotas.Info, quotas.Warning, quotas.Error} {
+		l := NewMemoryLogger(quota, uint(level))
+		l.discard = quota <= 0
+		ret.logs = append(ret.logs, l)
+	}
+	return ret
+}
+
+// EnableMemoryStore turns on logging to the memory store
+func EnableMemoryStore(s *MemoryStore) {
+	// The store always has one log for each level so this can't fail
+	o, _ := memoryOutputs(s.logs)
+	publish(o)
+}
+
+// Logs returns the memory logs in the store, one for each level
+func (s *MemoryStore) Logs() []*MemoryLogger {
+	return s.logs
+}
+
+// Entries returns the entries for all levels, oldest first
+func (s *MemoryStore) Entries() []LogEntry {
+	return s.logs[0].Merge(s.logs[1:]...)
+}
+
+// Query returns the entries for all levels that match the query
+func (s *MemoryStore) Query(q Query) []LogEntry {
+	return QueryLogs(q, s.logs...)
+}
+
+// NumEntries returns the total number of entries written to the store
+func (s *MemoryStore) NumEntries() int {
+	total := 0
+	for _, l := range s.logs {
+		total += l.NumEntries()
+	}
+	return total
+}
+
+// Subscribe returns a subscription for new entries in all levels. See
+// MemoryLogger.Subscribe for details. Entries from different levels may be
+// delivered out of order.
+func (s *MemoryStore) Subscribe(ctx context.Context, q Query, bufferSize int) *Subscription {
+	var levels []*MemoryLogger
+	for _, l := range s.logs {
+		if q.matchLevel(l.level) {
+			levels = append(levels, l)
+		}
+	}
+	if bufferSize <= 0 {
+		bufferSize = DefaultSubscriptionBuffer
+	}
+	ret := &Subscription{entries: make(chan LogEntry, bufferSize)}
+	var wg sync.WaitGroup
+	for _, l := range levels {
+		sub := l.Subscribe(ctx, q, bufferSize)
+		ret.children = append(ret.children, sub)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range sub.Entries() {
+				select {
+				case ret.entries <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(ret.entries)
+	}()
+	return ret
+}
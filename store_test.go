@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(MemoryQuotas{Debug: 10, Info: 10, Warning: 10, Error: 3})
+	EnableMemoryStore(store)
+	defer EnableStderr(true)
+	SetLogLevel(DebugLevel)
+	defer SetLogLevel(WarningLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := store.Subscribe(ctx, Query{Levels: []uint{ErrorLevel}}, 0)
+	for i := 0; i < 5; i++ {
+		Error("error %d", i)
+		for j := 0; j < 100; j++ {
+			Debug("debug chatter %d", j)
+		}
+	}
+	errors := store.Query(Query{Levels: []uint{ErrorLevel}})
+	if len(errors) != 3 || errors[0].Message != "error 2" {
+		t.Fatalf("Expected the last 3 errors to be kept but got %+v", errors)
+	}
+	entries := store.Entries()
+	if len(entries) != 13 || store.NumEntries() != 505 {
+		t.Fatalf("Expected 13 of 505 entries but got %d of %d", len(entries), store.NumEntries())
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Seq <= entries[i-1].Seq {
+			t.Fatal("Entries should be ordered")
+		}
+	}
+	// Errors might be overwritten before they are delivered
+	for received := 0; received+int(sub.Dropped()) < 5; received++ {
+		if e := receive(t, sub); e.Level != ErrorLevel {
+			t.Fatalf("Expected only errors but got %+v", e)
+		}
+	}
+	cancel()
+	for range sub.Entries() {
+	}
+}
+
+func TestMemoryStoreZeroQuota(t *testing.T) {
+	store := NewMemoryStore(MemoryQuotas{Debug: 0, Info: 5, Warning: 5, Error: 5})
+	EnableMemoryStore(store)
+	defer EnableStderr(true)
+	SetLogLevel(DebugLevel)
+	defer SetLogLevel(WarningLevel)
+
+	Debug("not kept")
+	Info("kept")
+	if n := store.Logs()[DebugLevel].NumEntries(); n != 0 {
+		t.Fatalf("Expected no debug entries with a zero quota but got %d", n)
+	}
+	if entries := store.Entries(); len(entries) != 1 || entries[0].Message != "kept" {
+		t.Fatalf("Expected the info entry only: %+v", entries)
+	}
+
+	// The quota applies when the logs are used directly as well
+	logger, err := NewLogger(store.Logs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("not kept")
+	fmt.Fprintf(store.Logs()[DebugLevel], "main.go:1: not kept\n")
+	if n := store.Logs()[DebugLevel].NumEntries(); n != 0 {
+		t.Fatalf("Expected no debug entries from the logger but got %d", n)
+	}
+}
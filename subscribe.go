@@ -0,0 +1,123 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"context"
+	"math"
+	"sync/atomic"
+)
+
+// DefaultSubscriptionBuffer is the buffer size used for subscriptions when
+// the buffer size is 0 or less.
+const DefaultSubscriptionBuffer = 64
+
+// Subscription is a stream of new entries from a memory log. The writers
+// never wait for subscribers; they just signal that there are new entries.
+// If a subscriber falls so far behind that entries are overwritten in the
+// ring buffer before they are delivered the entries are dropped and counted.
+type Subscription struct {
+	entries  chan LogEntry
+	notify   chan struct{}
+	dropped  uint64
+	children []*Subscription // The subscriptions for each log in a MemoryStore
+}
+
+// Subscribe returns a subscription for new entries that match the query.
+// Limit and Reverse are ignored. The subscription's channel has room for
+// bufferSize entries. The subscription ends and the channel is closed when
+// the context is cancelled.
+func (m *MemoryLogger) Subscribe(ctx context.Context, q Query, bufferSize int) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultSubscriptionBuffer
+	}
+	s := &Subscription{
+		entries: make(chan LogEntry, bufferSize),
+		notify:  make(chan struct{}, 1),
+	}
+	m.mutex.Lock()
+	next := m.count + 1
+	if m.subscribers == nil {
+		m.subscribers = make(map[*Subscription]struct{})
+	}
+	m.subscribers[s] = struct{}{}
+	m.mutex.Unlock()
+
+	go s.run(ctx, m, q, next)
+	return s
+}
+
+// Entries returns the channel with the new entries. The channel is closed
+// when the subscription ends.
+func (s *Subscription) Entries() <-chan LogEntry {
+	return s.entries
+}
+
+// Dropped returns the number of entries that were overwritten before they
+// could be delivered to the subscriber.
+func (s *Subscription) Dropped() uint64 {
+	dropped := atomic.LoadUint64(&s.dropped)
+	for _, c := range s.children {
+		dropped += c.Dropped()
+	}
+	return dropped
+}
+
+// run delivers the entries from the position next until the context
+// is cancelled.
+func (s *Subscription) run(ctx context.Context, m *MemoryLogger, q Query, next uint64) {
+	defer close(s.entries)
+	defer m.unsubscribe(s)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.notify:
+		}
+		for _, slot := range m.snapshot(next, math.MaxUint64) {
+			if slot.pos > next {
+				atomic.AddUint64(&s.dropped, slot.pos-next)
+			}
+			next = slot.pos + 1
+			if !q.matchSlot(&slot, m.level) {
+				continue
+			}
+			e := slot.entry()
+			if !q.Match(e) {
+				continue
+			}
+			select {
+			case s.entries <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// signal tells the subscription that there are new entries
+func (s *Subscription) signal() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (m *MemoryLogger) unsubscribe(s *Subscription) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.subscribers, s)
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	ml := NewMemoryLogger(100, InfoLevel)
+	fmt.Fprintf(ml, "main.go:1: before subscribing\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	all := ml.Subscribe(ctx, Query{}, 0)
+	filtered := ml.Subscribe(ctx, Query{Text: "odd"}, 0)
+	for i := 0; i < 10; i++ {
+		if i%2 == 1 {
+			fmt.Fprintf(ml, "main.go:%d: odd %d\n", i, i)
+			continue
+		}
+		fmt.Fprintf(ml, "main.go:%d: even %d\n", i, i)
+	}
+	for i := 0; i < 10; i++ {
+		e := receive(t, all)
+		if e.Location != fmt.Sprintf("main.go:%d", i) {
+			t.Fatalf("Expected entry %d but got %+v", i, e)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if e := receive(t, filtered); e.Message != fmt.Sprintf(" odd %d\n", i*2+1) {
+			t.Fatalf("Unexpected entry: %+v", e)
+		}
+	}
+
+	cancel()
+	for _, s := range []*Subscription{all, filtered} {
+		select {
+		case _, ok := <-s.Entries():
+			if ok {
+				t.Fatal("Did not expect more entries")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected channel to be closed")
+		}
+	}
+	ml.mutex.Lock()
+	defer ml.mutex.Unlock()
+	if len(ml.subscribers) != 0 {
+		t.Fatalf("Expected no subscribers but there are %d", len(ml.subscribers))
+	}
+}
+
+func TestSubscribeSlowConsumer(t *testing.T) {
+	ml := NewMemoryLogger(5, InfoLevel)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := ml.Subscribe(ctx, Query{}, 1)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(ml, "main.go:%d: entry\n", i)
+	}
+	received := 0
+	for received+int(s.Dropped()) < 100 {
+		receive(t, s)
+		received++
+	}
+	if s.Dropped() == 0 || received+int(s.Dropped()) != 100 {
+		t.Fatalf("Expected dropped entries but got %d received and %d dropped", received, s.Dropped())
+	}
+}
+
+func receive(t *testing.T, s *Subscription) LogEntry {
+	t.Helper()
+	select {
+	case e := <-s.Entries():
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for entry")
+	}
+	return LogEntry{}
+}
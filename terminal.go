@@ -0,0 +1,89 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// isTerminal returns true if the file is a character device, ie a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// detectTerminal checks if colors and unicode can be used for the terminal.
+// NO_COLOR (see no-color.org) turns off colors, FORCE_COLOR turns colors on
+// even if the output isn't a terminal and TERM=dumb turns colors off. Unicode
+// is assumed to be supported if the locale uses UTF-8.
+func detectTerminal(getenv func(string) string, tty bool) (color bool, unicode bool) {
+	force := getenv("FORCE_COLOR")
+	switch {
+	case getenv("NO_COLOR") != "":
+		color = false
+	case force != "" && force != "0" && force != "false":
+		color = true
+	case force == "0" || force == "false":
+		color = false
+	case getenv("TERM") == "dumb":
+		color = false
+	default:
+		color = tty
+	}
+
+	locale := ""
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale = getenv(name); locale != "" {
+			break
+		}
+	}
+	locale = strings.ToLower(locale)
+	unicode = strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+	return color, unicode
+}
+
+// resetWriter appends the ANSI reset code to each line written so the colors
+// don't leak into the following output.
+type resetWriter struct {
+	w io.Writer
+}
+
+func (r *resetWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, 0, len(p)+len(resetText))
+	if bytes.HasSuffix(p, []byte("\n")) {
+		buf = append(append(append(buf, p[:len(p)-1]...), resetText...), '\n')
+	} else {
+		buf = append(append(buf, p...), resetText...)
+	}
+	if _, err := r.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Sync syncs the underlying writer if it supports it
+func (r *resetWriter) Sync() error {
+	if s, ok := r.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDetectTerminal(t *testing.T) {
+	tests := []struct {
+		env     map[string]string
+		tty     bool
+		color   bool
+		unicode bool
+	}{
+		{map[string]string{}, false, false, false},
+		{map[string]string{"LANG": "en_US.UTF-8"}, true, true, true},
+		{map[string]string{"LANG": "C", "TERM": "xterm"}, true, true, false},
+		{map[string]string{"LC_ALL": "nb_NO.utf8", "LANG": "C"}, true, true, true},
+		{map[string]string{"NO_COLOR": "1"}, true, false, false},
+		{map[string]string{"NO_COLOR": "1", "FORCE_COLOR": "1"}, true, false, false},
+		{map[string]string{"FORCE_COLOR": "1"}, false, true, false},
+		{map[string]string{"FORCE_COLOR": "0"}, true, false, false},
+		{map[string]string{"TERM": "dumb"}, true, false, false},
+		{map[string]string{"TERM": "dumb", "FORCE_COLOR": "true"}, true, true, false},
+	}
+	for i, test := range tests {
+		color, unicode := detectTerminal(func(name string) string { return test.env[name] }, test.tty)
+		if color != test.color || unicode != test.unicode {
+			t.Errorf("Test %d: expected color=%t unicode=%t but got %t/%t", i, test.color, test.unicode, color, unicode)
+		}
+	}
+}
+
+func TestResetWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := &resetWriter{w: buf}
+	w.Write([]byte("\x1b[31;1mline\n"))
+	w.Write([]byte("no newline"))
+	if buf.String() != "\x1b[31;1mline"+resetText+"\nno newline"+resetText {
+		t.Fatalf("Incorrect output: %q", buf.String())
+	}
+}
@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime/trace"
@@ -22,14 +23,24 @@ func NewTerminalLogger(logs []*MemoryLogger) *TerminalLogger {
 	}
 }
 
+// NewStoreTerminalLogger creates a new TerminalLogger instance for the
+// MemoryStore.
+func NewStoreTerminalLogger(s *MemoryStore) *TerminalLogger {
+	return NewTerminalLogger(s.Logs())
+}
+
 // TerminalLogger is a logger that creates a console logging screen with logs
 // that can be toggled runtime.
 type TerminalLogger struct {
-	logs      []*MemoryLogger
-	enabled   []bool
-	appName   string
-	mutex     sync.Mutex
-	traceFile *os.File
+	logs          []*MemoryLogger
+	enabled       []bool
+	appName       string
+	mutex         sync.Mutex
+	traceFile     *os.File
+	showDetails   bool
+	requestFilter string
+	editing       bool
+	input         []rune
 }
 
 // Split and pad lines with spaces to get an array of strings
@@ -63,33 +74,31 @@ func (t *TerminalLogger) Start() error {
 	t.draw()
 
 	quit := make(chan bool)
-	go func() {
-		counters := make([]int, 4)
-		for {
-			redraw := false
-			for i := 0; i < 4; i++ {
-				if t.logs[i].NumEntries() > counters[i] {
-					counters[i] = t.logs[i].NumEntries()
-					redraw = true
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 1)
+	for _, l := range t.logs[:ErrorLevel+1] {
+		go func(s *Subscription) {
+			for range s.Entries() {
+				select {
+				case changed <- struct{}{}:
+				default:
 				}
 			}
-			select {
-			case <-quit:
-				return
-			case <-time.After(75 * time.Millisecond):
-				// ok
-			}
-			if redraw {
-				t.draw()
-			}
-		}
-	}()
+		}(l.Subscribe(ctx, Query{}, 0))
+	}
+	go redrawLoop(changed, quit, redrawInterval, t.draw)
 	for {
 		ev := termbox.PollEvent()
 		if ev.Type == termbox.EventInterrupt {
 			quit <- true
 			return nil
 		}
+		if ev.Type == termbox.EventKey && t.isEditing() {
+			t.editFilter(ev)
+			t.draw()
+			continue
+		}
 		if ev.Type == termbox.EventKey {
 			switch ev.Key {
 			case termbox.KeyCtrlC:
@@ -109,12 +118,38 @@ func (t *TerminalLogger) Start() error {
 				t.toggle(ErrorLevel)
 			case termbox.KeyCtrlT:
 				t.toggleTrace()
+			case termbox.KeyCtrlS:
+				t.toggleDetails()
+			case termbox.KeyCtrlF:
+				t.startEditing()
 			}
 		}
 		t.draw()
 	}
 }
 
+// redrawInterval is the minimum time between redraws for new log entries
+const redrawInterval = 100 * time.Millisecond
+
+// redrawLoop calls draw when there are changes until quit is signalled. The
+// changes that arrive within the interval after a redraw are merged into
+// one redraw so a burst of log entries doesn't keep the terminal busy.
+func redrawLoop(changed <-chan struct{}, quit <-chan bool, interval time.Duration, draw func()) {
+	for {
+		select {
+		case <-quit:
+			return
+		case <-changed:
+			draw()
+		}
+		select {
+		case <-quit:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
 // toggle log levels on and off
 func (t *TerminalLogger) toggle(level uint) {
 	t.mutex.Lock()
@@ -122,6 +157,57 @@ func (t *TerminalLogger) toggle(level uint) {
 	t.enabled[level] = !t.enabled[level]
 }
 
+// toggle expansion of error chains and stack traces
+func (t *TerminalLogger) toggleDetails() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.showDetails = !t.showDetails
+}
+
+// SetRequestFilter limits the log entries shown to the ones with the
+// specified request ID. An empty string shows all entries.
+func (t *TerminalLogger) SetRequestFilter(requestID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.requestFilter = requestID
+}
+
+func (t *TerminalLogger) isEditing() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.editing
+}
+
+// start editing the request filter in the status bar
+func (t *TerminalLogger) startEditing() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.editing = true
+	t.input = []rune(t.requestFilter)
+}
+
+// handle a key press while editing the request filter. Enter applies the
+// filter and escape cancels the edit.
+func (t *TerminalLogger) editFilter(ev termbox.Event) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	switch ev.Key {
+	case termbox.KeyEnter:
+		t.requestFilter = strings.TrimSpace(string(t.input))
+		t.editing = false
+	case termbox.KeyEsc, termbox.KeyCtrlC:
+		t.editing = false
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	default:
+		if ev.Ch != 0 {
+			t.input = append(t.input, ev.Ch)
+		}
+	}
+}
+
 // Draw a string to the screen
 func (t *TerminalLogger) drawString(x, y, w int, text string, fg, bg termbox.Attribute) {
 	pos := x
@@ -137,8 +223,15 @@ func (t *TerminalLogger) drawString(x, y, w int, text string, fg, bg termbox.Att
 // Draw the title bar
 func (t *TerminalLogger) drawTitleBar(w int) {
 	caption := fmt.Sprintf("%s logs", t.appName)
+	if t.requestFilter != "" {
+		caption = fmt.Sprintf("%s logs for request %s", t.appName, t.requestFilter)
+	}
 	xpos := w/2 + len(caption)/2
-	title := fmt.Sprintf("%s%s%s", strings.Repeat(" ", xpos), caption, strings.Repeat(" ", w-xpos-len(t.appName)))
+	padding := w - xpos - len(t.appName)
+	if padding < 0 {
+		padding = 0
+	}
+	title := fmt.Sprintf("%s%s%s", strings.Repeat(" ", xpos), caption, strings.Repeat(" ", padding))
 	t.drawString(0, 0, w, title, termbox.ColorYellow|termbox.AttrBold, termbox.ColorBlue)
 }
 
@@ -156,7 +249,12 @@ func (t *TerminalLogger) drawIndicator(w, h, pos int, name string, enabled bool,
 
 // Draw the status bar
 func (t *TerminalLogger) drawStatusBar(w, h int) {
-	helpStr := fmt.Sprintf("Ctrl+D, I, W, E: Toggle levels (E:%d/W:%d/I:%d/D:%d), Ctrl+T: Toggle trace",
+	if t.editing {
+		t.drawString(0, h-1, w, strings.Repeat(" ", w), termbox.ColorYellow, termbox.ColorBlue)
+		t.drawString(1, h-1, w, "Request ID (Enter: apply, Esc: cancel): "+string(t.input), termbox.ColorYellow|termbox.AttrBold, termbox.ColorBlue)
+		return
+	}
+	helpStr := fmt.Sprintf("Ctrl+D, I, W, E: Toggle levels (E:%d/W:%d/I:%d/D:%d), Ctrl+T: Toggle trace, Ctrl+S: Toggle stacks, Ctrl+F: Filter request",
 		t.logs[ErrorLevel].NumEntries(),
 		t.logs[WarningLevel].NumEntries(),
 		t.logs[InfoLevel].NumEntries(),
@@ -169,6 +267,44 @@ func (t *TerminalLogger) drawStatusBar(w, h int) {
 	t.drawIndicator(w, h, 3, "I", t.enabled[InfoLevel], termbox.ColorBlack, termbox.ColorCyan)
 	t.drawIndicator(w, h, 4, "D", t.enabled[DebugLevel], termbox.ColorBlack, termbox.ColorWhite)
 	t.drawIndicator(w, h, 5, "T", t.traceFile != nil, termbox.ColorYellow, termbox.ColorRed)
+	t.drawIndicator(w, h, 6, "S", t.showDetails, termbox.ColorBlack, termbox.ColorGreen)
+}
+
+// Get the screen lines for a log entry. Error chains and stack traces are
+// shown as a single summary line unless the details are expanded.
+func (t *TerminalLogger) entryLines(e LogEntry, w int) []string {
+	prefix := fmt.Sprintf("%8s  %-20s ", e.Time.Format("15:04:05"), e.Location)
+	prefixLen := len(prefix)
+	blankPrefix := strings.Repeat(" ", prefixLen+1)
+	lines := splitAndPadLines(e.Message, w-prefixLen)
+	ret := []string{prefix + lines[0]}
+	for _, l := range lines[1:] {
+		ret = append(ret, blankPrefix+l)
+	}
+	if len(e.Causes) == 0 && len(e.Stack) == 0 {
+		return ret
+	}
+	var details []string
+	if !t.showDetails {
+		details = append(details, fmt.Sprintf("[+] %d error(s) in chain, %d stack frame(s)", len(e.Causes), len(e.Stack)))
+	} else {
+		for i, c := range e.Causes {
+			if i == 0 {
+				details = append(details, fmt.Sprintf("[-] error type %s", c.Type))
+				continue
+			}
+			details = append(details, fmt.Sprintf("    caused by %s: %s", c.Type, c.Message))
+		}
+		for _, f := range e.Stack {
+			details = append(details, "    at "+f.String())
+		}
+	}
+	for _, d := range details {
+		for _, l := range splitAndPadLines(d, w-prefixLen-1) {
+			ret = append(ret, blankPrefix+l)
+		}
+	}
+	return ret
 }
 
 // Draw the log entries
@@ -184,30 +320,26 @@ func (t *TerminalLogger) drawLogs(w, h int) {
 	}
 	elems := enabled[0].Merge(enabled[1:]...)
 	index := len(elems) - 1
-	for i := h - 2; i > 0; i-- {
-		if index > -1 {
-			prefix := fmt.Sprintf("%8s  %-20s ", elems[index].Time.Format("15:04:05"), elems[index].Location)
-			prefixLen := len(prefix)
-			lines := splitAndPadLines(elems[index].Message, w-prefixLen)
-			fg := termbox.ColorWhite
-			bg := termbox.ColorDefault
-			switch elems[index].Level {
-			case DebugLevel:
-				fg = termbox.ColorWhite
-			case InfoLevel:
-				fg = termbox.ColorBlue | termbox.AttrBold
-			case WarningLevel:
-				fg = termbox.ColorYellow | termbox.AttrBold
-			case ErrorLevel:
-				fg = termbox.ColorRed | termbox.AttrBold
-			}
-			blankPrefix := strings.Repeat(" ", prefixLen+1)
-			for n := len(lines) - 1; n > 0; n-- {
-				t.drawString(0, i, w, blankPrefix+lines[n], fg, bg)
-				i--
-			}
-			t.drawString(0, i, w, prefix+lines[0], fg, bg)
-			index--
+	for i := h - 2; i > 0 && index > -1; index-- {
+		if t.requestFilter != "" && elems[index].RequestID != t.requestFilter {
+			continue
+		}
+		fg := termbox.ColorWhite
+		bg := termbox.ColorDefault
+		switch elems[index].Level {
+		case DebugLevel:
+			fg = termbox.ColorWhite
+		case InfoLevel:
+			fg = termbox.ColorBlue | termbox.AttrBold
+		case WarningLevel:
+			fg = termbox.ColorYellow | termbox.AttrBold
+		case ErrorLevel:
+			fg = termbox.ColorRed | termbox.AttrBold
+		}
+		lines := t.entryLines(elems[index], w)
+		for n := len(lines) - 1; n >= 0 && i > 0; n-- {
+			t.drawString(0, i, w, lines[n], fg, bg)
+			i--
 		}
 	}
 }
@@ -30,6 +30,26 @@ func TestTermLogger(t *testing.T) {
 	term.Start()
 }
 
+func TestRedrawLoop(t *testing.T) {
+	changed := make(chan struct{}, 1)
+	quit := make(chan bool)
+	draws := make(chan struct{}, 100)
+	go redrawLoop(changed, quit, 50*time.Millisecond, func() { draws <- struct{}{} })
+
+	// A burst of changes is merged into a few redraws
+	for i := 0; i < 1000; i++ {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	time.Sleep(120 * time.Millisecond)
+	quit <- true
+	if n := len(draws); n < 1 || n > 3 {
+		t.Fatalf("Expected the changes to be merged but got %d redraws", n)
+	}
+}
+
 func TestSplitAndPad(t *testing.T) {
 	testSplits := func(str string, max int, expected int) {
 		split := splitAndPadLines(str, max)
@@ -0,0 +1,134 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"fmt"
+)
+
+// Color is a terminal foreground color. The zero value is the terminal's
+// default color.
+type Color struct {
+	code string
+}
+
+// Color16 returns one of the 16 standard terminal colors. 0-7 are the normal
+// colors (black, red, green, yellow, blue, magenta, cyan and white) and 8-15
+// the bright variants.
+func Color16(n uint8) Color {
+	if n < 8 {
+		return Color{code: fmt.Sprintf("%d", 30+n)}
+	}
+	return Color{code: fmt.Sprintf("%d", 90+(n&7))}
+}
+
+// Color256 returns one of the colors in the 256-color palette
+func Color256(n uint8) Color {
+	return Color{code: fmt.Sprintf("38;5;%d", n)}
+}
+
+// RGB returns a 24-bit (truecolor) color
+func RGB(r, g, b uint8) Color {
+	return Color{code: fmt.Sprintf("38;2;%d;%d;%d", r, g, b)}
+}
+
+// LevelStyle is the style for one log level
+type LevelStyle struct {
+	Prefix      string // Prefix used when the terminal supports unicode
+	PlainPrefix string // Prefix used for plain text or if unicode isn't supported
+	Color       Color  // The color
+	Bold        bool   // Use bold text
+}
+
+// escape returns the ANSI escape code for the style. The reset code is used
+// if the style has neither color nor bold text.
+func (s LevelStyle) escape() string {
+	switch {
+	case s.Color.code != "" && s.Bold:
+		return "\x1b[" + s.Color.code + ";1m"
+	case s.Color.code != "":
+		return "\x1b[" + s.Color.code + "m"
+	case s.Bold:
+		return "\x1b[1m"
+	}
+	return resetText
+}
+
+// Theme is the prefixes and colors used for the stderr output
+type Theme struct {
+	Log     LevelStyle // Style for the standard log package
+	Debug   LevelStyle
+	Info    LevelStyle
+	Warning LevelStyle
+	Error   LevelStyle
+	// TagOnly colors the prefix only rather than the entire line
+	TagOnly bool
+}
+
+// prefix returns the log prefix for the style
+func (t Theme) prefix(s LevelStyle, color, unicode bool) string {
+	if !color {
+		return s.PlainPrefix
+	}
+	tag := s.PlainPrefix
+	if unicode {
+		tag = s.Prefix
+	}
+	if t.TagOnly {
+		return s.escape() + tag + resetText
+	}
+	return s.escape() + tag
+}
+
+// Built-in themes. DarkTheme is the default.
+var (
+	// DarkTheme uses bright colors for terminals with a dark background
+	DarkTheme = Theme{
+		Log:     LevelStyle{Prefix: "💡   ", PlainPrefix: "LOG     "},
+		Debug:   LevelStyle{Prefix: "    ", PlainPrefix: "DEBUG   "},
+		Info:    LevelStyle{Prefix: "ℹ️   ", PlainPrefix: "INFO    ", Color: Color16(4), Bold: true},
+		Warning: LevelStyle{Prefix: "⚠️   ", PlainPrefix: "WARNING ", Color: Color16(3), Bold: true},
+		Error:   LevelStyle{Prefix: "🛑   ", PlainPrefix: "ERROR   ", Color: Color16(1), Bold: true},
+	}
+	// LightTheme uses darker colors for terminals with a light background
+	LightTheme = Theme{
+		Log:     LevelStyle{Prefix: "💡   ", PlainPrefix: "LOG     "},
+		Debug:   LevelStyle{Prefix: "    ", PlainPrefix: "DEBUG   ", Color: Color256(242)},
+		Info:    LevelStyle{Prefix: "ℹ️   ", PlainPrefix: "INFO    ", Color: Color16(4)},
+		Warning: LevelStyle{Prefix: "⚠️   ", PlainPrefix: "WARNING ", Color: Color256(130), Bold: true},
+		Error:   LevelStyle{Prefix: "🛑   ", PlainPrefix: "ERROR   ", Color: Color16(1), Bold: true},
+	}
+	// MonochromeTheme uses no colors but shows warnings and errors in bold
+	MonochromeTheme = Theme{
+		Log:     LevelStyle{Prefix: "💡   ", PlainPrefix: "LOG     "},
+		Debug:   LevelStyle{Prefix: "    ", PlainPrefix: "DEBUG   "},
+		Info:    LevelStyle{Prefix: "ℹ️   ", PlainPrefix: "INFO    "},
+		Warning: LevelStyle{Prefix: "⚠️   ", PlainPrefix: "WARNING ", Bold: true},
+		Error:   LevelStyle{Prefix: "🛑   ", PlainPrefix: "ERROR   ", Bold: true},
+	}
+)
+
+// SetTheme sets the theme for the stderr output. The theme is used the next
+// time EnableStderr is called.
+func SetTheme(theme Theme) {
+	updateSettings(func(s *settings) {
+		s.theme = theme
+	})
+}
+
+func getTheme() Theme {
+	return currentSettings.Load().(*settings).theme
+}
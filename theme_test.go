@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestThemePrefix(t *testing.T) {
+	if p := DarkTheme.prefix(DarkTheme.Info, true, true); p != "\x1b[34;1mℹ️   " {
+		t.Fatalf("Dark theme should keep the original info prefix but got %q", p)
+	}
+	if p := DarkTheme.prefix(DarkTheme.Debug, true, true); p != resetText+"    " {
+		t.Fatalf("Dark theme should keep the original debug prefix but got %q", p)
+	}
+	if p := DarkTheme.prefix(DarkTheme.Error, false, true); p != "ERROR   " {
+		t.Fatalf("Expected plain prefix without colors but got %q", p)
+	}
+	if p := LightTheme.prefix(LightTheme.Warning, true, false); p != "\x1b[38;5;130;1mWARNING " {
+		t.Fatalf("Expected plain prefix with 256 color but got %q", p)
+	}
+
+	tagOnly := MonochromeTheme
+	tagOnly.TagOnly = true
+	tagOnly.Info.Color = RGB(1, 2, 3)
+	if p := tagOnly.prefix(tagOnly.Info, true, false); p != "\x1b[38;2;1;2;3mINFO    "+resetText {
+		t.Fatalf("Expected colored tag but got %q", p)
+	}
+	if c := Color16(9); c.code != "91" {
+		t.Fatalf("Expected bright red but got %s", c.code)
+	}
+}
+
+func TestSetTheme(t *testing.T) {
+	theme, outputs := getTheme(), currentSettings.Load().(*settings).outputs
+	t.Cleanup(func() {
+		SetTheme(theme)
+		publish(outputs)
+	})
+	setLogLevel(t, WarningLevel)
+
+	var buf bytes.Buffer
+	SetTheme(LightTheme)
+	publish(themedOutputs(&buf, getTheme(), true, false))
+	Warning("This is a warning with the light theme")
+	if line := buf.String(); !strings.HasPrefix(line, "\x1b[38;5;130;1mWARNING ") || !strings.HasSuffix(line, "light theme"+resetText+"\n") {
+		t.Fatalf("Expected the light theme's colors on the entire line but got %q", line)
+	}
+
+	buf.Reset()
+	tagOnly := LightTheme
+	tagOnly.TagOnly = true
+	SetTheme(tagOnly)
+	publish(themedOutputs(&buf, getTheme(), true, true))
+	Warning("This is a warning with a colored tag")
+	if line := buf.String(); !strings.HasPrefix(line, "\x1b[38;5;130;1m⚠️   "+resetText) || !strings.HasSuffix(line, "colored tag\n") {
+		t.Fatalf("Expected only the tag to be colored but got %q", line)
+	}
+}
@@ -0,0 +1,197 @@
+package logging
+
+//
+//Copyright 2018 Telenor Digital AS
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//http://www.apache.org/licenses/LICENSE-2.0
+//
+//Unless required by applicable law or agreed to in writing, software
+//distributed under the License is distributed on an "AS IS" BASIS,
+//WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//See the License for the specific language governing permissions and
+//limitations under the License.
+//
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConfigWatcher reloads the configuration file when the process receives
+// SIGHUP or when the file changes. The new configuration is applied with
+// Configure so the levels, outputs and sampling are swapped at once and
+// messages that are being written complete with the old outputs. Invalid
+// configurations are logged and the previous configuration is kept.
+type ConfigWatcher struct {
+	filename  string
+	envPrefix string
+	mutex     sync.Mutex
+	current   Config
+	modTime   time.Time
+	size      int64
+	signals   chan os.Signal
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewConfigWatcher loads and applies the configuration file, then starts
+// watching it for changes. The file is checked for changes at the poll
+// interval; an interval of 0 disables the checks so the configuration is only
+// reloaded on SIGHUP. If envPrefix is set the environment variables are
+// applied on top of the file (see Config.LoadEnv).
+func NewConfigWatcher(filename string, envPrefix string, pollInterval time.Duration) (*ConfigWatcher, error) {
+	w := &ConfigWatcher{
+		filename:  filename,
+		envPrefix: envPrefix,
+		signals:   make(chan os.Signal, 1),
+		done:      make(chan struct{}),
+	}
+	config, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := Configure(config); err != nil {
+		return nil, err
+	}
+	w.current = config
+
+	signal.Notify(w.signals, syscall.SIGHUP)
+	w.wg.Add(1)
+	go w.watch(pollInterval)
+	return w, nil
+}
+
+// Close stops watching the configuration file. It is safe to call Close
+// more than once.
+func (w *ConfigWatcher) Close() {
+	w.closeOnce.Do(func() {
+		signal.Stop(w.signals)
+		close(w.done)
+	})
+	w.wg.Wait()
+}
+
+// Reload reads the configuration file and applies it. A summary of the
+// changes is logged at info level. If the configuration is invalid the error
+// is logged and returned and the current configuration is kept.
+func (w *ConfigWatcher) Reload() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	config, err := w.load()
+	if err == nil {
+		err = Configure(config)
+	}
+	if err != nil {
+		Error("Unable to reload logging configuration from %s, keeping the current configuration: %v", w.filename, err)
+		return err
+	}
+	changes := configChanges(w.current, config)
+	w.current = config
+	if len(changes) == 0 {
+		Info("Reloaded logging configuration from %s, no changes", w.filename)
+		return nil
+	}
+	Info("Reloaded logging configuration from %s: %s", w.filename, strings.Join(changes, ", "))
+	return nil
+}
+
+// load reads the configuration file and environment and records the file's
+// modification time and size.
+func (w *ConfigWatcher) load() (Config, error) {
+	if fi, err := os.Stat(w.filename); err == nil {
+		w.modTime, w.size = fi.ModTime(), fi.Size()
+	}
+	config, err := LoadConfig(w.filename)
+	if err != nil {
+		return config, err
+	}
+	if w.envPrefix != "" {
+		if err := config.LoadEnv(w.envPrefix); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// changed returns true if the file's modification time or size has changed
+func (w *ConfigWatcher) changed() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	fi, err := os.Stat(w.filename)
+	if err != nil {
+		return false
+	}
+	return !fi.ModTime().Equal(w.modTime) || fi.Size() != w.size
+}
+
+func (w *ConfigWatcher) watch(pollInterval time.Duration) {
+	defer w.wg.Done()
+	var tick <-chan time.Time
+	if pollInterval > 0 {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.signals:
+			w.Reload()
+		case <-tick:
+			if w.changed() {
+				w.Reload()
+			}
+		}
+	}
+}
+
+// configChanges returns a description of the differences between the two
+// configurations.
+func configChanges(old, new Config) []string {
+	var ret []string
+	str := func(name, a, b string) {
+		if a != b {
+			ret = append(ret, fmt.Sprintf("%s %q -> %q", name, a, b))
+		}
+	}
+	num := func(name string, a, b int) {
+		if a != b {
+			ret = append(ret, fmt.Sprintf("%s %d -> %d", name, a, b))
+		}
+	}
+	str("level", old.Level, new.Level)
+	str("output", old.Output, new.Output)
+	str("format", old.Format, new.Format)
+	str("theme", old.Theme, new.Theme)
+	str("syslog name", old.SyslogName, new.SyslogName)
+	num("memory entries", old.MemoryEntries, new.MemoryEntries)
+	num("sampling initial", old.Sampling.Initial, new.Sampling.Initial)
+	num("sampling thereafter", old.Sampling.Thereafter, new.Sampling.Thereafter)
+	str("sampling interval", old.Sampling.Interval, new.Sampling.Interval)
+
+	var names []string
+	for name := range old.Components {
+		names = append(names, name)
+	}
+	for name := range new.Components {
+		if _, ok := old.Components[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		str("component "+name, old.Components[name], new.Components[name])
+	}
+	return ret
+}
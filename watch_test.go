@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestConfigWatcher(t *testing.T) {
+	defer Configure(Config{})
+	filename := filepath.Join(t.TempDir(), "logging.json")
+	writeConfig := func(config string) {
+		if err := ioutil.WriteFile(filename, []byte(config), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(`{"output": "memory", "level": "warning"}`)
+
+	w, err := NewConfigWatcher(filename, "", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	logs := MemoryLogs()
+	if len(logs) != 4 {
+		t.Fatal("Expected memory output")
+	}
+
+	waitFor := func(level uint, text string) {
+		start := time.Now()
+		for time.Since(start) < 2*time.Second {
+			for _, e := range logs[level].Entries() {
+				if strings.Contains(e.Message, text) {
+					return
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for %q", text)
+	}
+
+	// Make sure the modification time changes
+	writeConfig(`{"output": "memory", "level": "info", "components": {"store": "debug"}}`)
+	os.Chtimes(filename, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	waitFor(InfoLevel, `level "warning" -> "info", component store "" -> "debug"`)
+	if MemoryLogs()[0] != logs[0] {
+		t.Fatal("Memory logs should be kept when reloading")
+	}
+
+	writeConfig(`{"output": "memory", "level": "chatty"}`)
+	if err := w.Reload(); err == nil {
+		t.Fatal("Expected error for invalid configuration")
+	}
+	waitFor(ErrorLevel, "keeping the current configuration")
+	Info("Still at info level")
+	waitFor(InfoLevel, "Still at info level")
+
+	writeConfig(`{"output": "memory", "level": "info", "components": {"store": "debug"}}`)
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	waitFor(InfoLevel, "no changes")
+
+	w.Close()
+	w.Close()
+}